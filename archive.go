@@ -0,0 +1,200 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/v2/retryhttp"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+func isRemoteArchivePath(archivePath string) bool {
+	return strings.HasPrefix(archivePath, "https://") || strings.HasPrefix(archivePath, "http://")
+}
+
+// redactArchiveURL returns the archive URL without the query, the fragment and the user info,
+// as presigned URLs carry their access tokens in them.
+func redactArchiveURL(archiveURL string) string {
+	parsed, err := url.Parse(archiveURL)
+	if err != nil {
+		return "<invalid URL>"
+	}
+	parsed.User = nil
+	parsed.RawQuery = ""
+	parsed.ForceQuery = false
+	parsed.Fragment = ""
+	parsed.RawFragment = ""
+	return parsed.String()
+}
+
+// parseArchiveChecksum returns the lowercase hex SHA-256 checksum, the `sha256:` prefix is optional.
+func parseArchiveChecksum(checksum string) (string, error) {
+	checksum = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(checksum), "sha256:"))
+	if checksum == "" {
+		return "", nil
+	}
+
+	if decoded, err := hex.DecodeString(checksum); err != nil || len(decoded) != sha256.Size {
+		return "", fmt.Errorf("invalid archive checksum (%s), a hex encoded SHA-256 checksum is expected", checksum)
+	}
+	return checksum, nil
+}
+
+// prepareArchive resolves the archive path input to a local .xcarchive directory.
+// Zipped archives are unzipped and remote archives are downloaded into a temporary directory,
+// the returned tmpDir should be removed after the export, it is empty if the archive is used in place.
+func (s Step) prepareArchive(archivePath, checksum string) (xcarchivePath string, tmpDir string, err error) {
+	archivePath = strings.TrimSpace(archivePath)
+	isRemote := isRemoteArchivePath(archivePath)
+	isZip := isRemote || strings.HasSuffix(strings.ToLower(archivePath), ".zip")
+
+	if !isZip {
+		if checksum != "" {
+			return "", "", fmt.Errorf("archive checksum can only be verified for zipped archives")
+		}
+		if exist, err := pathutil.IsDirExists(archivePath); err != nil {
+			return "", "", fmt.Errorf("failed to check if archive exists, error: %s", err)
+		} else if !exist {
+			return "", "", fmt.Errorf("archive does not exist at: %s", archivePath)
+		}
+		return archivePath, "", nil
+	}
+
+	// The returned tmpDir is reset on the error paths, the deferred cleanup uses its own copy.
+	archiveTmpDir, err := pathutil.NormalizedOSTempDirPath("__archive__")
+	if err != nil {
+		return "", "", fmt.Errorf("failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			s.cleanupArchive(archiveTmpDir)
+		}
+	}()
+
+	zipPath := archivePath
+	if isRemote {
+		zipPath = filepath.Join(archiveTmpDir, "archive.xcarchive.zip")
+		s.logger.Printf("Downloading archive from %s", redactArchiveURL(archivePath))
+		if err := s.downloadArchive(archivePath, zipPath); err != nil {
+			return "", "", err
+		}
+	}
+
+	if checksum != "" {
+		if err := verifyFileChecksum(zipPath, checksum); err != nil {
+			return "", "", err
+		}
+		s.logger.Printf("Archive checksum verified")
+	}
+
+	s.logger.Printf("Unzipping archive")
+	// UnzipXcarchive creates its own tmp dir, and returns it even if unzipping fails.
+	unzipDir, err := v1xcarchive.UnzipXcarchive(zipPath)
+	if err != nil {
+		s.cleanupArchive(unzipDir)
+		return "", "", fmt.Errorf("failed to unzip archive, error: %s", err)
+	}
+	// Move it under ours to clean up both at once.
+	xcarchiveDir := filepath.Join(archiveTmpDir, "unzipped")
+	if err := os.Rename(unzipDir, xcarchiveDir); err != nil {
+		s.cleanupArchive(unzipDir)
+		return "", "", fmt.Errorf("failed to move unzipped archive, error: %s", err)
+	}
+
+	xcarchivePath, err = findXcarchive(xcarchiveDir)
+	if err != nil {
+		return "", "", err
+	}
+
+	return xcarchivePath, archiveTmpDir, nil
+}
+
+// findXcarchive returns the single .xcarchive in the dir, the dir is expected to be the unzipped content of an .xcarchive.zip.
+func findXcarchive(dir string) (string, error) {
+	xcarchives, err := filepath.Glob(filepath.Join(dir, "*.xcarchive"))
+	if err != nil {
+		return "", fmt.Errorf("failed to search for .xcarchive, error: %s", err)
+	}
+	if len(xcarchives) != 1 {
+		return "", fmt.Errorf("the zip should contain exactly one .xcarchive, found: %d", len(xcarchives))
+	}
+	return xcarchives[0], nil
+}
+
+func (s Step) downloadArchive(archiveURL, destination string) error {
+	resp, err := retryhttp.NewClient(s.logger).Get(archiveURL)
+	if err != nil {
+		// The errors of the HTTP client contain the full URL, only their cause is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			return fmt.Errorf("failed to download archive from %s, error: %s", redactArchiveURL(archiveURL), urlErr.Err)
+		}
+		return fmt.Errorf("failed to download archive from %s", redactArchiveURL(archiveURL))
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to download archive, status code: %d", resp.StatusCode)
+	}
+
+	file, err := os.Create(destination)
+	if err != nil {
+		return fmt.Errorf("failed to create file (%s), error: %s", destination, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close file (%s), error: %s", destination, err)
+		}
+	}()
+
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		return fmt.Errorf("failed to download archive, error: %s", err)
+	}
+	return nil
+}
+
+func verifyFileChecksum(pth, checksum string) error {
+	file, err := os.Open(pth)
+	if err != nil {
+		return fmt.Errorf("failed to open (%s), error: %s", pth, err)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Warnf("Failed to close file (%s), error: %s", pth, err)
+		}
+	}()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return fmt.Errorf("failed to calculate checksum of (%s), error: %s", pth, err)
+	}
+
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return fmt.Errorf("archive checksum mismatch, expected: %s, actual: %s", checksum, actual)
+	}
+	return nil
+}
+
+// cleanupArchive removes the temporary directory of a downloaded or unzipped archive.
+func (s Step) cleanupArchive(tmpDir string) {
+	if tmpDir == "" {
+		return
+	}
+	if err := os.RemoveAll(tmpDir); err != nil {
+		s.logger.Warnf("Failed to remove temporary archive dir (%s), error: %s", tmpDir, err)
+	}
+}
@@ -0,0 +1,193 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+// CodesignAssetProvider lists the code signing certificates and provisioning profiles
+// the code sign groups of the export are resolved from.
+type CodesignAssetProvider interface {
+	ListCodesignIdentities() ([]certificateutil.CertificateInfoModel, error)
+	ListInstallerIdentities() ([]certificateutil.CertificateInfoModel, error)
+	ListProvisioningProfiles(profileType profileutil.ProfileType) ([]profileutil.ProvisioningProfileInfoModel, error)
+}
+
+// LocalCodesignAssetProvider reads the certificates installed in the keychain
+// and the provisioning profiles installed on the machine.
+type LocalCodesignAssetProvider struct{}
+
+// NewLocalCodesignAssetProvider ...
+func NewLocalCodesignAssetProvider() CodesignAssetProvider {
+	return LocalCodesignAssetProvider{}
+}
+
+// ListCodesignIdentities ...
+func (LocalCodesignAssetProvider) ListCodesignIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := certificateutil.InstalledCodesigningCertificateInfos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installed certificates, error: %s", err)
+	}
+	return certificateutil.FilterValidCertificateInfos(certs).ValidCertificates, nil
+}
+
+// ListInstallerIdentities ...
+func (LocalCodesignAssetProvider) ListInstallerIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := certificateutil.InstalledInstallerCertificateInfos()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installed installer certificates, error: %s", err)
+	}
+	return certificateutil.FilterValidCertificateInfos(certs).ValidCertificates, nil
+}
+
+// ListProvisioningProfiles returns the installed profiles of the given platform type.
+// Profiles of a platform not known by profileutil (for example visionOS only profiles) are skipped.
+func (LocalCodesignAssetProvider) ListProvisioningProfiles(profileType profileutil.ProfileType) ([]profileutil.ProvisioningProfileInfoModel, error) {
+	installedProfiles, err := profileutil.InstalledProvisioningProfiles(profileType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get installed provisioning profiles, error: %s", err)
+	}
+
+	var profs []profileutil.ProvisioningProfileInfoModel
+	for _, installedProfile := range installedProfiles {
+		if installedProfile == nil {
+			continue
+		}
+
+		profile, err := profileutil.NewProvisioningProfileInfo(*installedProfile)
+		if err != nil {
+			log.Debugf("Skipping provisioning profile: %s", err)
+			continue
+		}
+
+		if profile.Type == profileType {
+			profs = append(profs, profile)
+		}
+	}
+
+	return profs, nil
+}
+
+// DirectoryCodesignAssetProvider reads .p12 certificates and provisioning profiles from directories,
+// so it does not depend on the keychain and can be used on any platform.
+type DirectoryCodesignAssetProvider struct {
+	certificatesDir string
+	passphrases     []string
+	profilesDir     string
+}
+
+// NewDirectoryCodesignAssetProvider creates a provider reading the .p12 files of certificatesDir
+// and the .mobileprovision and .provisionprofile files of profilesDir.
+// The passphrases are matched to the .p12 files in alphabetical order,
+// a single passphrase is used for every .p12 file.
+func NewDirectoryCodesignAssetProvider(certificatesDir string, passphrases []string, profilesDir string) CodesignAssetProvider {
+	return DirectoryCodesignAssetProvider{
+		certificatesDir: certificatesDir,
+		passphrases:     passphrases,
+		profilesDir:     profilesDir,
+	}
+}
+
+// ListCodesignIdentities ...
+func (p DirectoryCodesignAssetProvider) ListCodesignIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := p.listCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	return certificateutil.FilterCertificateInfoModelsByFilterFunc(certs, func(cert certificateutil.CertificateInfoModel) bool {
+		return !isInstallerCertificate(cert)
+	}), nil
+}
+
+// ListInstallerIdentities ...
+func (p DirectoryCodesignAssetProvider) ListInstallerIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	certs, err := p.listCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	return certificateutil.FilterCertificateInfoModelsByFilterFunc(certs, isInstallerCertificate), nil
+}
+
+func (p DirectoryCodesignAssetProvider) listCertificates() ([]certificateutil.CertificateInfoModel, error) {
+	pths, err := listFilesWithExtensions(p.certificatesDir, ".p12")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list certificates in (%s), error: %s", p.certificatesDir, err)
+	}
+
+	if len(p.passphrases) > 1 && len(p.passphrases) != len(pths) {
+		return nil, fmt.Errorf("%d certificates found in (%s), but %d passphrases provided", len(pths), p.certificatesDir, len(p.passphrases))
+	}
+
+	var certs []certificateutil.CertificateInfoModel
+	for i, pth := range pths {
+		passphrase := ""
+		if len(p.passphrases) == 1 {
+			passphrase = p.passphrases[0]
+		} else if len(p.passphrases) > 1 {
+			passphrase = p.passphrases[i]
+		}
+
+		infos, err := certificateutil.CertificatesFromPKCS12File(pth, passphrase)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse certificate (%s), error: %s", pth, err)
+		}
+		certs = append(certs, infos...)
+	}
+
+	return certificateutil.FilterValidCertificateInfos(certs).ValidCertificates, nil
+}
+
+// ListProvisioningProfiles ...
+func (p DirectoryCodesignAssetProvider) ListProvisioningProfiles(profileType profileutil.ProfileType) ([]profileutil.ProvisioningProfileInfoModel, error) {
+	pths, err := listFilesWithExtensions(p.profilesDir, ".mobileprovision", ".provisionprofile")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list provisioning profiles in (%s), error: %s", p.profilesDir, err)
+	}
+
+	var profs []profileutil.ProvisioningProfileInfoModel
+	for _, pth := range pths {
+		profile, err := profileutil.NewProvisioningProfileInfoFromFile(pth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse provisioning profile (%s), error: %s", pth, err)
+		}
+
+		if profile.Type == profileType {
+			profs = append(profs, profile)
+		}
+	}
+
+	return profs, nil
+}
+
+// isInstallerCertificate reports whether the certificate signs installer packages (Mac Installer Distribution, Developer ID Installer),
+// the same way certificateutil.InstalledInstallerCertificateInfos does for the keychain.
+func isInstallerCertificate(cert certificateutil.CertificateInfoModel) bool {
+	return strings.Contains(cert.CommonName, "Installer")
+}
+
+func listFilesWithExtensions(dir string, exts ...string) ([]string, error) {
+	if dir == "" {
+		return nil, nil
+	}
+
+	var pths []string
+	for _, ext := range exts {
+		matches, err := filepath.Glob(filepath.Join(pathutil.EscapeGlobPath(dir), "*"+ext))
+		if err != nil {
+			return nil, err
+		}
+		pths = append(pths, matches...)
+	}
+	sort.Strings(pths)
+
+	return pths, nil
+}
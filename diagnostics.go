@@ -0,0 +1,286 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/ryanuber/go-glob"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+// Code sign group filters
+const (
+	codeSignFilterProfileMatch    = "profile match"
+	codeSignFilterMappedProfiles  = "profile mapping"
+	codeSignFilterEntitlements    = "entitlements"
+	codeSignFilterExportMethod    = "export method"
+	codeSignFilterTeam            = "team"
+	codeSignFilterNotXcodeManaged = "not Xcode managed"
+	codeSignFilterDefaultProfile  = "default profile exclusion"
+	codeSignFilterNoCertificate   = "installed certificates"
+)
+
+// codeSignDecisionRemovedByOther is the decision of a bundle ID in a group removed because of another bundle ID.
+const codeSignDecisionRemovedByOther = "kept its profiles, the group was removed because of another target"
+
+// profileRemovalReason returns why the filter removes the profile from the bundle ID's candidates of the group,
+// empty if the filter keeps the profile.
+type profileRemovalReason func(certificate certificateutil.CertificateInfoModel, bundleID string, profile profileutil.ProvisioningProfileInfoModel) string
+
+// codeSignGroupRemoval is a candidate code sign group (a certificate with profiles for every bundle ID) removed by a filter.
+type codeSignGroupRemoval struct {
+	Filter      string
+	Certificate certificateutil.CertificateInfoModel
+	// Reasons maps the bundle IDs to why the filter removed their profiles.
+	Reasons map[string][]string
+}
+
+// codeSignDiagnostics records which filter removed each candidate code sign group and why,
+// to explain the failure when no group is left to sign the archive with.
+type codeSignDiagnostics struct {
+	bundleIDs []string
+	removals  []codeSignGroupRemoval
+}
+
+func newCodeSignDiagnostics(bundleIDs []string) *codeSignDiagnostics {
+	sortedBundleIDs := append([]string{}, bundleIDs...)
+	sort.Strings(sortedBundleIDs)
+	return &codeSignDiagnostics{bundleIDs: sortedBundleIDs}
+}
+
+// explainMissingGroups records the installed certificates, which did not make a candidate group,
+// because no installed profile including them matches every bundle ID.
+func (d *codeSignDiagnostics) explainMissingGroups(certificates []certificateutil.CertificateInfoModel, profiles []profileutil.ProvisioningProfileInfoModel, groups []export.SelectableCodeSignGroup) {
+	if len(certificates) == 0 {
+		d.removals = append(d.removals, codeSignGroupRemoval{
+			Filter:  codeSignFilterNoCertificate,
+			Reasons: d.reasonForEveryBundleID("no code signing certificate is installed"),
+		})
+		return
+	}
+
+	grouped := map[string]bool{}
+	for _, group := range groups {
+		grouped[group.Certificate.Serial] = true
+	}
+
+	for _, certificate := range certificates {
+		if grouped[certificate.Serial] {
+			continue
+		}
+
+		reasons := map[string][]string{}
+		for _, bundleID := range d.bundleIDs {
+			matches := false
+			for _, profile := range profiles {
+				if profileContainsCertificate(profile, certificate) && glob.Glob(profile.BundleID, bundleID) {
+					matches = true
+					break
+				}
+			}
+			if !matches {
+				reasons[bundleID] = []string{fmt.Sprintf("no installed profile for the bundle ID includes certificate %s", certificate.CommonName)}
+			}
+		}
+
+		d.removals = append(d.removals, codeSignGroupRemoval{
+			Filter:      codeSignFilterProfileMatch,
+			Certificate: certificate,
+			Reasons:     reasons,
+		})
+	}
+}
+
+// filter applies the filter on the groups and records the removed groups.
+func (d *codeSignDiagnostics) filter(groups []export.SelectableCodeSignGroup, name string, filter export.SelectableCodeSignGroupFilter, reason profileRemovalReason) []export.SelectableCodeSignGroup {
+	filtered, removals := filterCodeSignGroups(groups, name, filter, reason)
+	d.removals = append(d.removals, removals...)
+	return filtered
+}
+
+// filterCodeSignGroups applies the filter on the groups, and explains the removal of the groups with the reason function.
+func filterCodeSignGroups(groups []export.SelectableCodeSignGroup, name string, filter export.SelectableCodeSignGroupFilter, reason profileRemovalReason) ([]export.SelectableCodeSignGroup, []codeSignGroupRemoval) {
+	var filtered []export.SelectableCodeSignGroup
+	var removals []codeSignGroupRemoval
+	for _, group := range groups {
+		if kept := export.FilterSelectableCodeSignGroups([]export.SelectableCodeSignGroup{group}, filter); len(kept) > 0 {
+			filtered = append(filtered, kept...)
+			continue
+		}
+
+		removal := codeSignGroupRemoval{
+			Filter:      name,
+			Certificate: group.Certificate,
+			Reasons:     map[string][]string{},
+		}
+		for bundleID, profiles := range group.BundleIDProfilesMap {
+			var profileReasons []string
+			for _, profile := range profiles {
+				if r := reason(group.Certificate, bundleID, profile); r != "" {
+					profileReasons = append(profileReasons, r)
+				}
+			}
+			// The filters remove the group if they remove every profile of any bundle ID
+			if len(profileReasons) == len(profiles) {
+				removal.Reasons[bundleID] = uniqueReasons(profileReasons)
+			}
+		}
+		removals = append(removals, removal)
+	}
+	return filtered, removals
+}
+
+func uniqueReasons(reasons []string) []string {
+	var unique []string
+	for _, reason := range reasons {
+		if !sliceutil.IsStringInSlice(reason, unique) {
+			unique = append(unique, reason)
+		}
+	}
+	return unique
+}
+
+func (d *codeSignDiagnostics) reasonForEveryBundleID(reason string) map[string][]string {
+	reasons := map[string][]string{}
+	for _, bundleID := range d.bundleIDs {
+		reasons[bundleID] = []string{reason}
+	}
+	return reasons
+}
+
+// decisionTable returns the decisions per bundle ID: every removed group, with the filter and the reason of the removal.
+func (d *codeSignDiagnostics) decisionTable() string {
+	var lines []string
+	for _, bundleID := range d.bundleIDs {
+		lines = append(lines, bundleID+":")
+		for _, removal := range d.removals {
+			certificate := "-"
+			if removal.Certificate.Serial != "" {
+				certificate = fmt.Sprintf("%s (%s)", removal.Certificate.CommonName, removal.Certificate.Serial)
+			}
+
+			reasons, ok := removal.Reasons[bundleID]
+			if !ok {
+				reasons = []string{codeSignDecisionRemovedByOther}
+			}
+			for _, reason := range reasons {
+				lines = append(lines, fmt.Sprintf("  - %s | %s filter | %s", certificate, removal.Filter, reason))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// noGroupError prints the decision table and returns the error explaining that no code sign group is left.
+func (d *codeSignDiagnostics) noGroupError(exportMethod exportoptions.Method) error {
+	log.Errorf("No code signing group found for the %s export method", exportMethod)
+	log.Printf("Code signing decisions per bundle ID (certificate | filter | reason):")
+	log.Printf("%s", d.decisionTable())
+
+	filters := map[string]bool{}
+	for _, removal := range d.removals {
+		filters[removal.Filter] = true
+	}
+	var filterNames []string
+	for filter := range filters {
+		filterNames = append(filterNames, filter)
+	}
+	sort.Strings(filterNames)
+
+	if len(filterNames) == 0 {
+		return fmt.Errorf("no code signing group found for the %s export method", exportMethod)
+	}
+	return fmt.Errorf("no code signing group found for the %s export method, every candidate was removed by the filters: %s", exportMethod, strings.Join(filterNames, ", "))
+}
+
+// noInstallerCertificateError prints the decision table and returns the error explaining that code sign groups are left,
+// but no installer certificate is installed to sign the package of the app-store export.
+func (d *codeSignDiagnostics) noInstallerCertificateError(exportMethod exportoptions.Method) error {
+	log.Errorf("No installer certificate (Mac Installer Distribution) found for the %s export method", exportMethod)
+	log.Printf("Code signing decisions per bundle ID (certificate | filter | reason):")
+	log.Printf("%s", d.decisionTable())
+
+	return fmt.Errorf("no installer certificate (Mac Installer Distribution) found for the %s export method, install one to sign the package", exportMethod)
+}
+
+func entitlementsRemovalReason(bundleIDEntitlementsMap map[string]plistutil.PlistData) profileRemovalReason {
+	return func(_ certificateutil.CertificateInfoModel, bundleID string, profile profileutil.ProvisioningProfileInfoModel) string {
+		missing := profileutil.MatchTargetAndProfileEntitlements(bundleIDEntitlementsMap[bundleID], profile.Entitlements, profile.Type)
+		if len(missing) == 0 {
+			return ""
+		}
+		sort.Strings(missing)
+		return fmt.Sprintf("profile %s (%s) lacks %s", profile.Name, profile.UUID, strings.Join(missing, ", "))
+	}
+}
+
+func exportMethodRemovalReason(exportMethod exportoptions.Method) profileRemovalReason {
+	return func(certificate certificateutil.CertificateInfoModel, _ string, profile profileutil.ProvisioningProfileInfoModel) string {
+		if profile.ExportType == exportMethod {
+			return ""
+		}
+		if isDevelopmentCertificate(certificate) && exportMethod != exportoptions.MethodDevelopment {
+			return fmt.Sprintf("certificate %s is development, but the export method is %s", certificate.CommonName, exportMethod)
+		}
+		return fmt.Sprintf("profile %s (%s) is %s, but the export method is %s", profile.Name, profile.UUID, profile.ExportType, exportMethod)
+	}
+}
+
+func teamRemovalReason(teamID string) profileRemovalReason {
+	return func(certificate certificateutil.CertificateInfoModel, _ string, _ profileutil.ProvisioningProfileInfoModel) string {
+		if certificate.TeamID == teamID {
+			return ""
+		}
+		return fmt.Sprintf("certificate %s belongs to team %s, but the export team is %s", certificate.CommonName, certificate.TeamID, teamID)
+	}
+}
+
+func notXcodeManagedRemovalReason(_ certificateutil.CertificateInfoModel, _ string, profile profileutil.ProvisioningProfileInfoModel) string {
+	if !profile.IsXcodeManaged() {
+		return ""
+	}
+	return fmt.Sprintf("profile %s (%s) is Xcode managed, but the archive was signed with a manually managed profile", profile.Name, profile.UUID)
+}
+
+func excludeProfileNameRemovalReason(name string) profileRemovalReason {
+	return func(_ certificateutil.CertificateInfoModel, _ string, profile profileutil.ProvisioningProfileInfoModel) string {
+		if profile.Name != name {
+			return ""
+		}
+		return fmt.Sprintf("profile %s (%s) is the default profile", profile.Name, profile.UUID)
+	}
+}
+
+func mappedProfilesRemovalReason(mappedProfiles map[string]profileutil.ProvisioningProfileInfoModel) profileRemovalReason {
+	return func(certificate certificateutil.CertificateInfoModel, _ string, _ profileutil.ProvisioningProfileInfoModel) string {
+		var bundleIDs []string
+		for bundleID := range mappedProfiles {
+			bundleIDs = append(bundleIDs, bundleID)
+		}
+		sort.Strings(bundleIDs)
+
+		for _, bundleID := range bundleIDs {
+			if profile := mappedProfiles[bundleID]; !profileContainsCertificate(profile, certificate) {
+				return fmt.Sprintf("mapped profile %s (%s) of %s does not include certificate %s", profile.Name, profile.UUID, bundleID, certificate.CommonName)
+			}
+		}
+		return ""
+	}
+}
+
+// isDevelopmentCertificate returns true for the development certificates, based on the certificate's common name.
+func isDevelopmentCertificate(certificate certificateutil.CertificateInfoModel) bool {
+	for _, prefix := range []string{"Apple Development", "iPhone Developer", "Mac Developer"} {
+		if strings.HasPrefix(certificate.CommonName, prefix) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+func TestConfig_generateExportOptions_plist_noCodeSignGroup(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	config := testExportOptionsConfig()
+	config.TeamID = "my team id"
+
+	// When
+	_, _, err := generator.generateExportOptionsPlist("development", archive, config)
+
+	// Then
+	assert.EqualError(t, err, "no code signing group found for the development export method, every candidate was removed by the filters: export method, team")
+}
+
+func TestCodeSignDiagnostics_decisionTable(t *testing.T) {
+	// Given
+	developmentCertificate := testCertificate("1", "Apple Development: Bitrise Bot (E89JV3W9K4)")
+	distributionCertificate := testCertificate("2", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	unusedCertificate := testCertificate("3", "Apple Distribution: Other Bot (72SA8V3WYL)")
+
+	appStoreProfile := testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, distributionCertificate)
+	widgetProfile := testProfile("Widget App Store", "widget-uuid", exportoptions.MethodAppStore, distributionCertificate)
+	widgetProfile.BundleID = testBundleID + ".widget"
+	developmentProfile := testProfile("Wildcard Development", "development-uuid", exportoptions.MethodDevelopment, developmentCertificate)
+	developmentProfile.BundleID = "*"
+	developmentProfile.Entitlements = plistutil.PlistData{"com.apple.developer.associated-domains": "*"}
+	profiles := []profileutil.ProvisioningProfileInfoModel{appStoreProfile, widgetProfile, developmentProfile}
+
+	bundleIDs := []string{testBundleID + ".widget", testBundleID}
+	entitlements := map[string]plistutil.PlistData{
+		testBundleID:             {"com.apple.developer.associated-domains": []string{"applinks:example.com"}},
+		testBundleID + ".widget": {},
+	}
+	certificates := []certificateutil.CertificateInfoModel{developmentCertificate, distributionCertificate, unusedCertificate}
+
+	// When
+	diagnostics := newCodeSignDiagnostics(bundleIDs)
+	groups := export.CreateSelectableCodeSignGroups(certificates, profiles, bundleIDs)
+	diagnostics.explainMissingGroups(certificates, profiles, groups)
+	groups = diagnostics.filter(groups, codeSignFilterEntitlements, export.CreateEntitlementsSelectableCodeSignGroupFilter(entitlements), entitlementsRemovalReason(entitlements))
+	groups = diagnostics.filter(groups, codeSignFilterExportMethod, export.CreateExportMethodSelectableCodeSignGroupFilter(exportoptions.MethodAppStore), exportMethodRemovalReason(exportoptions.MethodAppStore))
+
+	// Then
+	assert.Empty(t, groups)
+	assert.Equal(t, `io.bitrise.sample:
+  - Apple Distribution: Other Bot (72SA8V3WYL) (3) | profile match filter | no installed profile for the bundle ID includes certificate Apple Distribution: Other Bot (72SA8V3WYL)
+  - Apple Distribution: Bitrise Bot (72SA8V3WYL) (2) | entitlements filter | profile Sample App Store (app-store-uuid) lacks com.apple.developer.associated-domains
+  - Apple Development: Bitrise Bot (E89JV3W9K4) (1) | export method filter | certificate Apple Development: Bitrise Bot (E89JV3W9K4) is development, but the export method is app-store
+io.bitrise.sample.widget:
+  - Apple Distribution: Other Bot (72SA8V3WYL) (3) | profile match filter | no installed profile for the bundle ID includes certificate Apple Distribution: Other Bot (72SA8V3WYL)
+  - Apple Distribution: Bitrise Bot (72SA8V3WYL) (2) | entitlements filter | kept its profiles, the group was removed because of another target
+  - Apple Development: Bitrise Bot (E89JV3W9K4) (1) | export method filter | certificate Apple Development: Bitrise Bot (E89JV3W9K4) is development, but the export method is app-store`, diagnostics.decisionTable())
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"debug/macho"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// lcUUID is the Mach-O load command holding the binary's UUID, not defined by debug/macho.
+const lcUUID macho.LoadCmd = 0x1b
+
+// BinaryUUID is the UUID of a single architecture slice of a Mach-O binary.
+type BinaryUUID struct {
+	Arch string `json:"arch"`
+	UUID string `json:"uuid"`
+}
+
+// dsymUUIDs returns the UUIDs of the DWARF binaries in a .dSYM bundle.
+func dsymUUIDs(dsymPth string) ([]BinaryUUID, error) {
+	pattern := filepath.Join(dsymPth, "Contents", "Resources", "DWARF", "*")
+	pths, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var uuids []BinaryUUID
+	for _, pth := range pths {
+		binaryUUIDs, err := machoUUIDs(pth)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read UUIDs of (%s), error: %s", pth, err)
+		}
+		uuids = append(uuids, binaryUUIDs...)
+	}
+
+	return uuids, nil
+}
+
+// machoUUIDs returns the UUID of every architecture of a thin or fat (universal) Mach-O binary.
+func machoUUIDs(pth string) ([]BinaryUUID, error) {
+	fat, err := macho.OpenFat(pth)
+	if err == nil {
+		defer func() {
+			if err := fat.Close(); err != nil {
+				log.Errorf("Failed to close file (%s), error: %s", pth, err)
+			}
+		}()
+
+		var uuids []BinaryUUID
+		for _, arch := range fat.Arches {
+			uuids = append(uuids, BinaryUUID{
+				Arch: machoArchName(arch.File),
+				UUID: machoUUID(arch.File),
+			})
+		}
+		return uuids, nil
+	} else if !errors.Is(err, macho.ErrNotFat) {
+		return nil, err
+	}
+
+	file, err := macho.Open(pth)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Errorf("Failed to close file (%s), error: %s", pth, err)
+		}
+	}()
+
+	return []BinaryUUID{{
+		Arch: machoArchName(file),
+		UUID: machoUUID(file),
+	}}, nil
+}
+
+func machoUUID(file *macho.File) string {
+	for _, load := range file.Loads {
+		raw := load.Raw()
+		if len(raw) < 24 || macho.LoadCmd(file.ByteOrder.Uint32(raw[0:4])) != lcUUID {
+			continue
+		}
+
+		uuid := fmt.Sprintf("%X", raw[8:24])
+		return strings.Join([]string{uuid[0:8], uuid[8:12], uuid[12:16], uuid[16:20], uuid[20:32]}, "-")
+	}
+	return ""
+}
+
+func machoArchName(file *macho.File) string {
+	switch file.Cpu {
+	case macho.CpuArm64:
+		if file.SubCpu&0xff == 2 {
+			return "arm64e"
+		}
+		return "arm64"
+	case macho.CpuArm:
+		return "armv7"
+	case macho.CpuAmd64:
+		return "x86_64"
+	case macho.Cpu386:
+		return "i386"
+	default:
+		return strings.ToLower(strings.TrimPrefix(file.Cpu.String(), "Cpu"))
+	}
+}
+
+// dSYM scopes
+const (
+	dsymScopeApp              = "app"
+	dsymScopeAppAndExtensions = "app-and-extensions"
+	dsymScopeAll              = "all"
+)
+
+// archiveDSYMs are the debug symbols of the archive by category, limited to the selected dSYM scope.
+type archiveDSYMs struct {
+	App          []string
+	Extension    []string
+	Framework    []string
+	BCSymbolMaps []string
+	// Binaries are the executables of the archive the dSYMs in the scope belong to.
+	Binaries []string
+}
+
+// dSYMs returns every dSYM of the archive in the selected scope.
+func (d archiveDSYMs) dSYMs() []string {
+	var dsyms []string
+	dsyms = append(dsyms, d.App...)
+	dsyms = append(dsyms, d.Extension...)
+	dsyms = append(dsyms, d.Framework...)
+	return dsyms
+}
+
+// collectDSYMs sorts the non app dSYMs of the archive into extension and framework dSYMs,
+// and collects the BCSymbolMaps, depending on the scope.
+func collectDSYMs(archivePath string, appDSYMs, otherDSYMs []string, scope string) (archiveDSYMs, error) {
+	dsyms := archiveDSYMs{App: appDSYMs}
+	if scope == dsymScopeApp {
+		return dsyms, nil
+	}
+
+	for _, dsym := range otherDSYMs {
+		if strings.HasSuffix(dsym, ".appex.dSYM") {
+			dsyms.Extension = append(dsyms.Extension, dsym)
+		} else if scope == dsymScopeAll {
+			dsyms.Framework = append(dsyms.Framework, dsym)
+		}
+	}
+
+	if scope == dsymScopeAll {
+		bcSymbolMaps, err := filepath.Glob(filepath.Join(archivePath, "BCSymbolMaps", "*.bcsymbolmap"))
+		if err != nil {
+			return archiveDSYMs{}, fmt.Errorf("failed to collect BCSymbolMaps, error: %s", err)
+		}
+		dsyms.BCSymbolMaps = bcSymbolMaps
+	}
+
+	return dsyms, nil
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectDSYMs(t *testing.T) {
+	// Given
+	archivePath := t.TempDir()
+	bcSymbolMapsDir := filepath.Join(archivePath, "BCSymbolMaps")
+	assert.NoError(t, os.MkdirAll(bcSymbolMapsDir, 0755))
+	bcSymbolMap := filepath.Join(bcSymbolMapsDir, "A8C5C1F5-0E0C-3A5E-9B9A-2C5E0A1B2C3D.bcsymbolmap")
+	assert.NoError(t, os.WriteFile(bcSymbolMap, nil, 0644))
+
+	appDSYMs := []string{"dSYMs/Sample.app.dSYM"}
+	otherDSYMs := []string{"dSYMs/Widget.appex.dSYM", "dSYMs/Alamofire.framework.dSYM"}
+
+	tests := []struct {
+		scope string
+		want  archiveDSYMs
+	}{
+		{
+			scope: dsymScopeApp,
+			want:  archiveDSYMs{App: appDSYMs},
+		},
+		{
+			scope: dsymScopeAppAndExtensions,
+			want:  archiveDSYMs{App: appDSYMs, Extension: []string{"dSYMs/Widget.appex.dSYM"}},
+		},
+		{
+			scope: dsymScopeAll,
+			want: archiveDSYMs{
+				App:          appDSYMs,
+				Extension:    []string{"dSYMs/Widget.appex.dSYM"},
+				Framework:    []string{"dSYMs/Alamofire.framework.dSYM"},
+				BCSymbolMaps: []string{bcSymbolMap},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.scope, func(t *testing.T) {
+			// When
+			got, err := collectDSYMs(archivePath, appDSYMs, otherDSYMs, tt.scope)
+
+			// Then
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/log"
+)
+
+// Export failure codes
+const (
+	failureCodeExpiredCertificate         = "expired-certificate"
+	failureCodeExpiredProfile             = "expired-profile"
+	failureCodeMissingPrivateKey          = "missing-private-key"
+	failureCodeCertificateProfileMismatch = "certificate-profile-mismatch"
+	failureCodeNoMatchingProfile          = "no-matching-profile"
+	failureCodeEntitlementMismatch        = "entitlement-mismatch"
+	failureCodeBitcode                    = "bitcode"
+	failureCodeIPATool                    = "ipatool"
+	failureCodeUnknown                    = "unknown"
+)
+
+// exportFailure is a known reason of a failed xcodebuild export.
+type exportFailure struct {
+	// Code is stable across releases, it is exported in the failure reason output.
+	Code        string
+	Description string
+	Hint        string
+	// Evidence is the first log line matching the failure.
+	Evidence string
+}
+
+type exportFailurePattern struct {
+	failure exportFailure
+	pattern *regexp.Regexp
+}
+
+// exportFailurePatterns are matched in order, the more specific patterns come first.
+// The patterns match the error phrases of xcodebuild and ipatool, not the terms alone,
+// as the IDEDistribution logs of a successful export mention the same terms (private key, ipatool, bitcode).
+var exportFailurePatterns = []exportFailurePattern{
+	{
+		failure: exportFailure{
+			Code:        failureCodeExpiredCertificate,
+			Description: "the signing certificate is expired or revoked",
+			Hint:        "Renew the certificate on the Apple Developer Portal, upload it to Bitrise and regenerate the provisioning profiles including it.",
+		},
+		pattern: regexp.MustCompile(`(?i)certificate "[^"]*" (has expired|is expired|expired on|has been revoked|is revoked)|CSSMERR_TP_CERT_(EXPIRED|REVOKED)`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeExpiredProfile,
+			Description: "the provisioning profile is expired",
+			Hint:        "Regenerate the provisioning profile on the Apple Developer Portal and upload it to Bitrise, or enable automatic code signing.",
+		},
+		pattern: regexp.MustCompile(`(?i)provisioning profile "[^"]*" (has expired|is expired|expired on)`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeMissingPrivateKey,
+			Description: "the private key of the signing certificate is not installed",
+			Hint:        "Upload the certificate as a .p12 file exported together with its private key, the .cer file alone can not be used for signing.",
+		},
+		pattern: regexp.MustCompile(`(?i)No signing certificate "[^"]*" found|signing certificate .* with a private key was found|(is )?missing its private key|no (valid )?signing identities (were )?found`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeCertificateProfileMismatch,
+			Description: "the provisioning profile does not include the signing certificate",
+			Hint:        "Regenerate the provisioning profile including the installed certificate, or install the certificate the profile was created with.",
+		},
+		pattern: regexp.MustCompile(`(?i)provisioning profile "[^"]*" does(n't| not) include (the )?signing certificate`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeEntitlementMismatch,
+			Description: "the provisioning profile does not support the entitlements of a target",
+			Hint:        "Enable the capability for the App ID on the Apple Developer Portal, then regenerate the provisioning profile, or remove the entitlement from the target.",
+		},
+		pattern: regexp.MustCompile(`(?i)provisioning profile "[^"]*" does(n't| not) (support the .* capability|include the .* entitlement|match the entitlements)`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeNoMatchingProfile,
+			Description: "no provisioning profile matches a target of the archive",
+			Hint:        "Install a provisioning profile for every bundle ID of the archive with the requested distribution method, or enable automatic code signing.",
+		},
+		pattern: regexp.MustCompile(`(?i)no profiles for '[^']*' were found|"[^"]*" requires a provisioning profile|no "[^"]*" profiles for team "[^"]*" were found`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeBitcode,
+			Description: "bitcode recompilation failed",
+			Hint:        "Set the Rebuild from bitcode (compile_bitcode) and Include bitcode (upload_bitcode) inputs to no, bitcode is deprecated since Xcode 14.",
+		},
+		pattern: regexp.MustCompile(`(?i)(failed|unable) to (re)?compile (the )?bitcode|bitcode (re)?compilation failed`),
+	},
+	{
+		failure: exportFailure{
+			Code:        failureCodeIPATool,
+			Description: "ipatool failed to process the app",
+			Hint:        "Check the IDEDistribution logs in the xcdistributionlogs for the failing step of ipatool, it usually points to an invalid framework or a missing architecture.",
+		},
+		pattern: regexp.MustCompile(`(?i)ipatool failed|ipatool exited with`),
+	},
+}
+
+// classifyExportFailure matches the xcodebuild output and the IDEDistribution logs against the known export failures.
+func classifyExportFailure(xcodebuildOutput, ideDistributionLogDir string) exportFailure {
+	sources := []string{xcodebuildOutput}
+	if ideDistributionLogDir != "" {
+		logPaths, err := filepath.Glob(filepath.Join(ideDistributionLogDir, "IDEDistribution*.log"))
+		if err != nil {
+			log.Warnf("Failed to collect IDEDistribution logs, error: %s", err)
+		}
+		for _, logPath := range logPaths {
+			content, err := os.ReadFile(logPath)
+			if err != nil {
+				log.Warnf("Failed to read %s, error: %s", logPath, err)
+				continue
+			}
+			sources = append(sources, string(content))
+		}
+	}
+
+	for _, p := range exportFailurePatterns {
+		for _, source := range sources {
+			if evidence := firstMatchingLine(source, p.pattern); evidence != "" {
+				failure := p.failure
+				failure.Evidence = evidence
+				return failure
+			}
+		}
+	}
+
+	return exportFailure{
+		Code:        failureCodeUnknown,
+		Description: "unknown export failure",
+		Hint:        "Check the xcodebuild output and the IDEDistribution logs in the xcdistributionlogs.",
+	}
+}
+
+func firstMatchingLine(content string, pattern *regexp.Regexp) string {
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); pattern.MatchString(line) {
+			return line
+		}
+	}
+	return ""
+}
+
+// exportFailureError is returned if the xcodebuild export fails.
+type exportFailureError struct {
+	Failure exportFailure
+	Err     error
+}
+
+func (e exportFailureError) Error() string {
+	return fmt.Sprintf("export failed, %s (%s), error: %s", e.Failure.Description, e.Failure.Code, e.Err)
+}
+
+func (s Step) logExportFailure(failure exportFailure) {
+	s.logger.Println()
+	s.logger.Errorf("Export failure: %s (%s)", failure.Description, failure.Code)
+	if failure.Evidence != "" {
+		s.logger.Printf("Matching log line: %s", failure.Evidence)
+	}
+	s.logger.Warnf("Hint: %s", failure.Hint)
+
+	envKey := s.outputKey(bitriseExportFailureReasonEnvKey)
+	if err := tools.ExportEnvironmentWithEnvman(envKey, failure.Code); err != nil {
+		s.logger.Warnf("Failed to export %s, error: %s", envKey, err)
+		return
+	}
+	s.logger.Printf("The failure reason is now available in the Environment Variable: %s (value: %s)", envKey, failure.Code)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyExportFailure(t *testing.T) {
+	tests := []struct {
+		name     string
+		output   string
+		wantCode string
+	}{
+		{
+			name:     "no matching profile",
+			output:   `error: exportArchive: No profiles for 'io.bitrise.sample' were found`,
+			wantCode: failureCodeNoMatchingProfile,
+		},
+		{
+			name:     "missing private key",
+			output:   `error: exportArchive: No signing certificate "iOS Distribution" found`,
+			wantCode: failureCodeMissingPrivateKey,
+		},
+		{
+			name:     "missing private key of the certificate",
+			output:   `error: exportArchive: No "iOS Distribution" signing certificate matching team ID "72SA8V3WYL" with a private key was found.`,
+			wantCode: failureCodeMissingPrivateKey,
+		},
+		{
+			name:     "entitlement mismatch",
+			output:   `error: exportArchive: Provisioning profile "Sample AdHoc" doesn't support the Push Notifications capability.`,
+			wantCode: failureCodeEntitlementMismatch,
+		},
+		{
+			name:     "expired certificate",
+			output:   `error: exportArchive: Certificate "Apple Distribution: Bitrise Bot (72SA8V3WYL)" has expired`,
+			wantCode: failureCodeExpiredCertificate,
+		},
+		{
+			name:     "ipatool",
+			output:   `error: exportArchive: ipatool failed with an exception: #<CmdSpec::NonZeroExitException: ...>`,
+			wantCode: failureCodeIPATool,
+		},
+		{
+			name:     "unknown",
+			output:   `** EXPORT FAILED **`,
+			wantCode: failureCodeUnknown,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failure := classifyExportFailure("** EXPORT FAILED **\n"+tt.output+"\n", "")
+			assert.Equal(t, tt.wantCode, failure.Code)
+		})
+	}
+}
+
+func TestClassifyExportFailure_ideDistributionLogs(t *testing.T) {
+	// Given
+	logDir := t.TempDir()
+	content := "2024-01-01 12:00:00 +0000 [MT] Running /Applications/Xcode.app/Contents/Developer/usr/bin/ipatool\n" +
+		"2024-01-01 12:00:01 +0000 [MT] error: Failed to recompile bitcode for Sample.framework\n"
+	if err := os.WriteFile(filepath.Join(logDir, "IDEDistribution.standard.log"), []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write log: %s", err)
+	}
+
+	// When
+	failure := classifyExportFailure("error: exportArchive: exit status 70", logDir)
+
+	// Then
+	assert.Equal(t, failureCodeBitcode, failure.Code)
+	assert.Equal(t, "2024-01-01 12:00:01 +0000 [MT] error: Failed to recompile bitcode for Sample.framework", failure.Evidence)
+}
+
+func TestClassifyExportFailure_successfulDistributionLog(t *testing.T) {
+	// Given
+	logDir := t.TempDir()
+	content := `2024-01-01 12:00:00 +0000 [MT] Running /Applications/Xcode.app/Contents/Developer/usr/bin/ipatool '--json' '/var/folders/tmp/ipatool-json-filepath'
+2024-01-01 12:00:00 +0000 [MT] ipatool JSON: { alerts = ( ); }
+2024-01-01 12:00:01 +0000 [MT] Processing step: IDEDistributionSigningAssetStep
+2024-01-01 12:00:01 +0000 [MT] Looking up signing certificate "Apple Distribution: Bitrise Bot (72SA8V3WYL)" with a private key in the keychain
+2024-01-01 12:00:01 +0000 [MT] Certificate "Apple Distribution: Bitrise Bot (72SA8V3WYL)" expires on 2025-01-01
+2024-01-01 12:00:02 +0000 [MT] Provisioning profile "Sample App Store" includes signing certificate "Apple Distribution: Bitrise Bot (72SA8V3WYL)"
+2024-01-01 12:00:02 +0000 [MT] Entitlements of Sample.app match the entitlements of provisioning profile "Sample App Store"
+2024-01-01 12:00:03 +0000 [MT] Processing step: IDEDistributionRecompileBitcodeStep, compileBitcode = NO
+2024-01-01 12:00:04 +0000 [MT] Processing step: IDEDistributionWriteIPAStep
+`
+	if err := os.WriteFile(filepath.Join(logDir, "IDEDistribution.standard.log"), []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write log: %s", err)
+	}
+
+	// When
+	failure := classifyExportFailure("** EXPORT SUCCEEDED **", logDir)
+
+	// Then
+	assert.Equal(t, failureCodeUnknown, failure.Code)
+	assert.Empty(t, failure.Evidence)
+}
+
+func TestExportFailureError(t *testing.T) {
+	err := exportFailureError{
+		Failure: exportFailure{Code: failureCodeMissingPrivateKey, Description: "the private key of the signing certificate is not installed"},
+		Err:     errors.New("exit status 70"),
+	}
+	assert.Equal(t, "export failed, the private key of the signing certificate is not installed (missing-private-key), error: exit status 70", err.Error())
+}
+
+func TestExportIDEDistributionLogs_everyFailedMethod(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("zipping directories requires rsync")
+	}
+
+	// Given
+	recordPath := fakeEnvman(t)
+	step := Step{logger: &recordingLogger{}}
+	deployDir := t.TempDir()
+	var failedExports []MethodExport
+	for _, method := range []string{"ad-hoc", "app-store"} {
+		logDir := filepath.Join(t.TempDir(), method+".xcdistributionlogs")
+		assert.NoError(t, os.MkdirAll(logDir, 0755))
+		assert.NoError(t, os.WriteFile(filepath.Join(logDir, "IDEDistribution.standard.log"), []byte(method), 0600))
+		failedExports = append(failedExports, MethodExport{DistributionMethod: method, IDEDistrubutionLogDir: logDir})
+	}
+	opts := ExportOpts{DeployDir: deployDir, FileNames: newOutputFileNames("", []string{"ad-hoc", "app-store", "development"}, nil)}
+
+	// When
+	step.exportIDEDistributionLogs(opts, failedExports)
+
+	// Then
+	adHocZipPath := filepath.Join(deployDir, "xcodebuild.xcdistributionlogs_ad-hoc.zip")
+	appStoreZipPath := filepath.Join(deployDir, "xcodebuild.xcdistributionlogs_app-store.zip")
+	assert.FileExists(t, adHocZipPath)
+	assert.FileExists(t, appStoreZipPath)
+
+	content, err := os.ReadFile(recordPath)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "BITRISE_IDEDISTRIBUTION_LOGS_PATH_AD_HOC="+adHocZipPath+"\n")
+	assert.Contains(t, string(content), "BITRISE_IDEDISTRIBUTION_LOGS_PATH_APP_STORE="+appStoreZipPath+"\n")
+	assert.Contains(t, string(content), "BITRISE_IDEDISTRIBUTION_LOGS_PATH="+adHocZipPath+"\n")
+}
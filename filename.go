@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+// Output file name template placeholders
+const (
+	fileNamePlaceholderBundleID = "{bundle_id}"
+	fileNamePlaceholderVersion  = "{version}"
+	fileNamePlaceholderBuild    = "{build}"
+	fileNamePlaceholderMethod   = "{method}"
+	fileNamePlaceholderProduct  = "{product}"
+	fileNamePlaceholderTeamID   = "{team_id}"
+	fileNamePlaceholderDate     = "{date}"
+)
+
+var fileNamePlaceholders = []string{
+	fileNamePlaceholderBundleID,
+	fileNamePlaceholderVersion,
+	fileNamePlaceholderBuild,
+	fileNamePlaceholderMethod,
+	fileNamePlaceholderProduct,
+	fileNamePlaceholderTeamID,
+	fileNamePlaceholderDate,
+}
+
+var fileNamePlaceholderPattern = regexp.MustCompile(`{[^{}]*}`)
+
+// unsafeFileNameCharacters are replaced in the placeholder values, to keep the resolved name a single file name.
+var unsafeFileNameCharacters = regexp.MustCompile(`[/\\:*?"<>|\s]`)
+
+// parseFileNameTemplate validates the output file name template, an empty template keeps the default file names.
+func parseFileNameTemplate(template string) (string, error) {
+	template = strings.TrimSpace(template)
+	if template == "" {
+		return "", nil
+	}
+
+	if strings.ContainsAny(template, `/\`) {
+		return "", fmt.Errorf("the template should be a file name without a path separator: %s", template)
+	}
+	for _, placeholder := range fileNamePlaceholderPattern.FindAllString(template, -1) {
+		if !sliceutil.IsStringInSlice(placeholder, fileNamePlaceholders) {
+			return "", fmt.Errorf("unknown placeholder (%s), supported placeholders: %s", placeholder, strings.Join(fileNamePlaceholders, ", "))
+		}
+	}
+
+	return template, nil
+}
+
+// outputFileNames names the exported files after the file name template, or keeps the default names if the template is empty.
+type outputFileNames struct {
+	template            string
+	distributionMethods []string
+	// values maps the placeholders to their values resolved from the archive, except the distribution method.
+	values map[string]string
+}
+
+func newOutputFileNames(template string, distributionMethods []string, values map[string]string) outputFileNames {
+	return outputFileNames{
+		template:            template,
+		distributionMethods: distributionMethods,
+		values:              values,
+	}
+}
+
+// iosArchiveFileNameValues resolves the placeholders from the distributed product (the app or the App Clip) of the archive.
+func iosArchiveFileNameValues(archive xcarchive.IosArchive, product ExportProduct, date time.Time) map[string]string {
+	bundleID := exportedProductBundleID(archive, product)
+	infoPlist := exportedProductInfoPlist(archive, product)
+
+	return fileNameValues(bundleID, infoPlist, string(product), archive.Application.ProvisioningProfile.TeamID, date)
+}
+
+func macosArchiveFileNameValues(archive v1xcarchive.MacosArchive, product ExportProduct, date time.Time) map[string]string {
+	teamID := ""
+	if archive.Application.ProvisioningProfile != nil {
+		teamID = archive.Application.ProvisioningProfile.TeamID
+	}
+
+	return fileNameValues(archive.Application.BundleIdentifier(), archive.Application.InfoPlist, string(product), teamID, date)
+}
+
+func fileNameValues(bundleID string, infoPlist plistutil.PlistData, product, teamID string, date time.Time) map[string]string {
+	version, _ := infoPlist.GetString("CFBundleShortVersionString")
+	build, _ := infoPlist.GetString("CFBundleVersion")
+
+	return map[string]string{
+		fileNamePlaceholderBundleID: bundleID,
+		fileNamePlaceholderVersion:  version,
+		fileNamePlaceholderBuild:    build,
+		fileNamePlaceholderProduct:  product,
+		fileNamePlaceholderTeamID:   teamID,
+		fileNamePlaceholderDate:     date.Format("2006-01-02"),
+	}
+}
+
+// methodFileName returns the file name of a distribution method's file:
+// the default name suffixed with the distribution method if multiple methods are exported,
+// or the resolved template followed by the kind of the file (for example: .export_options) and the extension.
+// The kind keeps the files of the same extension apart, it is not used with the default names.
+// The resolved template is suffixed with the distribution method too, if it does not contain the method placeholder.
+// The default name is used if the template resolves to an empty name.
+func (n outputFileNames) methodFileName(defaultName, kind, ext, method string) string {
+	multipleMethods := len(n.distributionMethods) > 1
+	name := n.resolve(method)
+	if name == "" {
+		return distributionMethodFileName(defaultName, ext, method, multipleMethods)
+	}
+
+	multipleMethods = multipleMethods && !strings.Contains(n.template, fileNamePlaceholderMethod)
+	return distributionMethodFileName(name, kind+ext, method, multipleMethods)
+}
+
+// archiveFileName returns the file name of a file shared by the distribution methods (for example: the dSYM zip),
+// the method placeholder resolves to empty.
+// The default name is used if the template resolves to an empty name.
+func (n outputFileNames) archiveFileName(defaultName, kind, ext string) string {
+	name := n.resolve("")
+	if name == "" {
+		return defaultName + ext
+	}
+	return name + kind + ext
+}
+
+// resolve returns the template with the placeholders replaced,
+// the separators left at the ends by empty placeholder values are trimmed.
+func (n outputFileNames) resolve(method string) string {
+	var oldnew []string
+	for _, placeholder := range fileNamePlaceholders {
+		value := n.values[placeholder]
+		if placeholder == fileNamePlaceholderMethod {
+			value = method
+		}
+		oldnew = append(oldnew, placeholder, unsafeFileNameCharacters.ReplaceAllString(value, "_"))
+	}
+	return strings.Trim(strings.NewReplacer(oldnew...).Replace(n.template), "_-. ")
+}
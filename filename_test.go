@@ -0,0 +1,59 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/plistutil"
+)
+
+func TestParseFileNameTemplate(t *testing.T) {
+	template, err := parseFileNameTemplate(" {bundle_id}-{version}({build})-{method} ")
+	assert.NoError(t, err)
+	assert.Equal(t, "{bundle_id}-{version}({build})-{method}", template)
+
+	template, err = parseFileNameTemplate("")
+	assert.NoError(t, err)
+	assert.Equal(t, "", template)
+
+	_, err = parseFileNameTemplate("{bundle_id}-{branch}")
+	assert.EqualError(t, err, "unknown placeholder ({branch}), supported placeholders: {bundle_id}, {version}, {build}, {method}, {product}, {team_id}, {date}")
+
+	_, err = parseFileNameTemplate("builds/{bundle_id}")
+	assert.Error(t, err)
+}
+
+func TestOutputFileNames(t *testing.T) {
+	// Given
+	infoPlist := plistutil.PlistData{"CFBundleShortVersionString": "1.2.0", "CFBundleVersion": "42"}
+	values := fileNameValues(testBundleID, infoPlist, "app", testTeamID, time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+	methods := []string{"app-store", "ad-hoc"}
+
+	// When
+	withMethod := newOutputFileNames("{bundle_id}_{version}({build})_{method}", methods, values)
+	withoutMethod := newOutputFileNames("{product}-{team_id}-{date}", methods, values)
+	defaultNames := newOutputFileNames("", methods, values)
+
+	// Then
+	assert.Equal(t, "io.bitrise.sample_1.2.0(42)_ad-hoc.ipa", withMethod.methodFileName("Sample", "", ".ipa", "ad-hoc"))
+	assert.Equal(t, "io.bitrise.sample_1.2.0(42)_ad-hoc.export_options.plist", withMethod.methodFileName("export_options", ".export_options", ".plist", "ad-hoc"))
+	assert.Equal(t, "io.bitrise.sample_1.2.0(42).dSYM.zip", withMethod.archiveFileName("Sample", "", ".dSYM.zip"))
+
+	assert.Equal(t, "app-72SA8V3WYL-2026-10-16_ad-hoc.xcdistributionlogs.zip", withoutMethod.methodFileName("xcodebuild.xcdistributionlogs", ".xcdistributionlogs", ".zip", "ad-hoc"))
+
+	assert.Equal(t, "Sample_ad-hoc.ipa", defaultNames.methodFileName("Sample", "", ".ipa", "ad-hoc"))
+	assert.Equal(t, "Sample.dSYM.zip", defaultNames.archiveFileName("Sample", "", ".dSYM.zip"))
+}
+
+func TestOutputFileNames_emptyName(t *testing.T) {
+	// Given
+	values := fileNameValues(testBundleID, plistutil.PlistData{}, "app", "", time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC))
+
+	// When
+	fileNames := newOutputFileNames("{team_id}", []string{"app-store", "ad-hoc"}, values)
+
+	// Then
+	assert.Equal(t, "Sample_ad-hoc.ipa", fileNames.methodFileName("Sample", "", ".ipa", "ad-hoc"))
+	assert.Equal(t, "Sample.dSYM.zip", fileNames.archiveFileName("Sample", "", ".dSYM.zip"))
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+)
+
+// ExportedIPA is a deployed IPA in the IPA map output.
+type ExportedIPA struct {
+	// Path is the key of the IPA in the IPA map.
+	Path               string `json:"-"`
+	DistributionMethod string `json:"distribution_method"`
+	// BundleID and App are the bundle ID and the name of the application in the Payload of the IPA,
+	// empty if the IPA could not be read.
+	BundleID string `json:"bundle_id"`
+	App      string `json:"app"`
+	// Primary is true for the IPA exported as the distribution method's IPA path.
+	Primary bool `json:"primary"`
+}
+
+// exportedProductBundleID returns the bundle ID of the distributed product (the app or the App Clip) of the archive.
+func exportedProductBundleID(archive xcarchive.IosArchive, product ExportProduct) string {
+	if product == ExportProductAppClip && archive.Application.ClipApplication != nil {
+		return archive.Application.ClipApplication.BundleIdentifier()
+	}
+	return archive.Application.BundleIdentifier()
+}
+
+// exportedProductInfoPlist returns the Info.plist of the distributed product (the app or the App Clip) of the archive.
+func exportedProductInfoPlist(archive xcarchive.IosArchive, product ExportProduct) plistutil.PlistData {
+	if product == ExportProductAppClip && archive.Application.ClipApplication != nil {
+		return archive.Application.ClipApplication.InfoPlist
+	}
+	return archive.Application.InfoPlist
+}
+
+// readIPAApp returns the bundle ID and the name of the application in the Payload of the IPA.
+func readIPAApp(ipaPath string) (string, string, error) {
+	targets, err := readIPATargets(ipaPath)
+	if err != nil {
+		return "", "", err
+	}
+
+	for _, target := range targets {
+		// The application is the only bundle directly in the Payload, extensions and watch apps are nested in it
+		if dir, name := path.Split(target.Path); dir == "Payload/" && strings.HasSuffix(name, ".app") {
+			return target.BundleID, strings.TrimSuffix(name, ".app"), nil
+		}
+	}
+
+	return "", "", fmt.Errorf("no application found in the Payload of the ipa (%s)", ipaPath)
+}
+
+// selectPrimaryIPA marks the primary IPA of a distribution method's IPAs, and returns its index:
+// the IPA containing the application with the distributed product's bundle ID,
+// or if no IPA contains it, the first IPA in alphabetical order of the IPA paths.
+func selectPrimaryIPA(ipas []ExportedIPA, productBundleID string) int {
+	primary := -1
+	for i, ipa := range ipas {
+		if productBundleID != "" && ipa.BundleID == productBundleID {
+			primary = i
+			break
+		}
+	}
+	if primary == -1 {
+		for i, ipa := range ipas {
+			if primary == -1 || ipa.Path < ipas[primary].Path {
+				primary = i
+			}
+		}
+	}
+
+	if primary != -1 {
+		ipas[primary].Primary = true
+	}
+	return primary
+}
+
+func ipaPaths(ipas []ExportedIPA) []string {
+	var pths []string
+	for _, ipa := range ipas {
+		pths = append(pths, ipa.Path)
+	}
+	return pths
+}
+
+// exportIPAMap exports the JSON map of every deployed IPA path to the application it contains.
+func (s Step) exportIPAMap(deployDir string, ipas []ExportedIPA) error {
+	ipaMap := map[string]ExportedIPA{}
+	for _, ipa := range ipas {
+		ipaMap[ipa.Path] = ipa
+	}
+
+	content, err := json.MarshalIndent(ipaMap, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal ipa map, error: %s", err)
+	}
+
+	mapPath := filepath.Join(deployDir, "ipa_map.json")
+	envKey := s.outputKey(bitriseIPAMapPthEnvKey)
+	if err := s.exportOutputFileContent(string(content), mapPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The ipa map path is now available in the Environment Variable: %s (value: %s)", envKey, mapPath)
+
+	return nil
+}
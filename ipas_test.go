@@ -0,0 +1,81 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+func writeTestIPA(t *testing.T, pth string, bundleIDs map[string]string) {
+	file, err := os.Create(pth)
+	assert.NoError(t, err)
+	writer := zip.NewWriter(file)
+	for name, bundleID := range bundleIDs {
+		w, err := writer.Create(name)
+		assert.NoError(t, err)
+		_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>CFBundleIdentifier</key><string>` + bundleID + `</string></dict></plist>`))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, file.Close())
+}
+
+func TestReadIPAApp(t *testing.T) {
+	// Given
+	ipaPath := filepath.Join(t.TempDir(), "Sample.ipa")
+	writeTestIPA(t, ipaPath, map[string]string{
+		"Payload/Sample.app/Info.plist":                      testBundleID,
+		"Payload/Sample.app/PlugIns/Widget.appex/Info.plist": testBundleID + ".widget",
+		"Payload/Sample.app/Watch/Watch.app/Info.plist":      testBundleID + ".watchkitapp",
+	})
+
+	// When
+	bundleID, app, err := readIPAApp(ipaPath)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, testBundleID, bundleID)
+	assert.Equal(t, "Sample", app)
+}
+
+func TestSelectPrimaryIPA(t *testing.T) {
+	t.Run("matching bundle ID", func(t *testing.T) {
+		ipas := []ExportedIPA{
+			{Path: "/deploy/Clip.ipa", BundleID: testBundleID + ".Clip"},
+			{Path: "/deploy/Sample.ipa", BundleID: testBundleID},
+		}
+
+		assert.Equal(t, 1, selectPrimaryIPA(ipas, testBundleID))
+		assert.Equal(t, []bool{false, true}, []bool{ipas[0].Primary, ipas[1].Primary})
+	})
+
+	t.Run("no matching bundle ID", func(t *testing.T) {
+		ipas := []ExportedIPA{
+			{Path: "/deploy/Sample.ipa"},
+			{Path: "/deploy/Clip.ipa"},
+		}
+
+		assert.Equal(t, 1, selectPrimaryIPA(ipas, testBundleID))
+		assert.True(t, ipas[1].Primary)
+	})
+}
+
+func TestExportedProductInfoPlist(t *testing.T) {
+	// Given
+	archive := testArchive(testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore))
+	clipInfoPlist := plistutil.PlistData{"CFBundleIdentifier": testBundleID + ".Clip", "CFBundleShortVersionString": "1.2.0"}
+	archive.Application.ClipApplication = &v1xcarchive.IosClipApplication{
+		IosBaseApplication: v1xcarchive.IosBaseApplication{InfoPlist: clipInfoPlist},
+	}
+
+	// Then
+	assert.Equal(t, archive.Application.InfoPlist, exportedProductInfoPlist(archive, ExportProductApp))
+	assert.Equal(t, clipInfoPlist, exportedProductInfoPlist(archive, ExportProductAppClip))
+}
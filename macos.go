@@ -0,0 +1,272 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	v1command "github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/ziputil"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/profileutil"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+func (g exportOptionsGenerator) generateMacExportOptionsPlist(exportMethodStr, teamID string, xcodebuildMajorVersion int64, archive v1xcarchive.MacosArchive) (string, ExportCodeSigning, error) {
+	log.Printf("Generating export options")
+
+	exportMethod, err := parseExportMethod(exportMethodStr)
+	if err != nil {
+		return "", ExportCodeSigning{}, fmt.Errorf("failed to parse export options, error: %s", err)
+	}
+	log.Printf("export-method specified: %s", exportMethodStr)
+
+	exportTeamID := ""
+	exportCertificate := certificateutil.CertificateInfoModel{}
+	exportInstallerCertificate := certificateutil.CertificateInfoModel{}
+	exportProfileMapping := map[string]string{}
+	exportProfiles := map[string]profileutil.ProvisioningProfileInfoModel{}
+	exportCodeSignStyle := ""
+
+	if xcodebuildMajorVersion >= 9 && archive.Application.ProvisioningProfile == nil {
+		log.Printf("No provisioning profile embedded in the archive, xcodebuild selects the signing certificate")
+	} else if xcodebuildMajorVersion >= 9 {
+		log.Printf("xcode major version > 9, generating provisioningProfiles node")
+
+		bundleIDEntitlementsMap := archive.BundleIDEntitlementsMap()
+		var bundleIDs []string
+		for bundleID := range bundleIDEntitlementsMap {
+			bundleIDs = append(bundleIDs, bundleID)
+		}
+
+		certs, err := g.codesignAssets.ListCodesignIdentities()
+		if err != nil {
+			return "", ExportCodeSigning{}, err
+		}
+
+		log.Debugf("Installed certificates:")
+		for _, certInfo := range certs {
+			log.Debugf(certInfo.String())
+		}
+
+		profs, err := g.codesignAssets.ListProvisioningProfiles(profileutil.ProfileTypeMacOs)
+		if err != nil {
+			return "", ExportCodeSigning{}, err
+		}
+
+		log.Debugf("Installed profiles:")
+		for _, profileInfo := range profs {
+			log.Debugf(profileInfo.String(certs...))
+		}
+
+		log.Printf("Resolving CodeSignGroups...")
+		codeSignGroups := export.CreateSelectableCodeSignGroups(certs, profs, bundleIDs)
+
+		diagnostics := newCodeSignDiagnostics(bundleIDs)
+		diagnostics.explainMissingGroups(certs, profs, codeSignGroups)
+
+		codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterEntitlements, export.CreateEntitlementsSelectableCodeSignGroupFilter(bundleIDEntitlementsMap), entitlementsRemovalReason(bundleIDEntitlementsMap))
+		codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterExportMethod, export.CreateExportMethodSelectableCodeSignGroupFilter(exportMethod), exportMethodRemovalReason(exportMethod))
+
+		if teamID != "" {
+			log.Warnf("Export TeamID specified: %s, filtering CodeSignInfo groups...", teamID)
+
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterTeam, export.CreateTeamSelectableCodeSignGroupFilter(teamID), teamRemovalReason(teamID))
+		}
+
+		if !archive.IsXcodeManaged() {
+			log.Warnf("App was signed with NON xcode managed profile when archiving,\n" +
+				"only NOT xcode managed profiles are allowed to sign when exporting the archive.\n" +
+				"Removing xcode managed CodeSignInfo groups")
+
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterNotXcodeManaged, export.CreateNotXcodeManagedSelectableCodeSignGroupFilter(), notXcodeManagedRemovalReason)
+		}
+
+		log.Debugf("\nGroups after filtering:")
+		for _, group := range codeSignGroups {
+			log.Debugf(group.String())
+		}
+
+		if g.strict {
+			for _, group := range codeSignGroups {
+				for bundleID, profiles := range group.BundleIDProfilesMap {
+					if len(profiles) == 0 {
+						return "", ExportCodeSigning{}, missingProfileError{BundleID: bundleID}
+					}
+				}
+			}
+		}
+
+		var installerCerts []certificateutil.CertificateInfoModel
+		if exportMethod == exportoptions.MethodAppStore {
+			installerCerts, err = g.codesignAssets.ListInstallerIdentities()
+			if err != nil {
+				return "", ExportCodeSigning{}, err
+			}
+
+			log.Debugf("Installed installer certificates:")
+			for _, certInfo := range installerCerts {
+				log.Debugf(certInfo.String())
+			}
+		}
+
+		macCodeSignGroups := export.CreateMacCodeSignGroup(codeSignGroups, installerCerts, exportMethod)
+		if len(macCodeSignGroups) > 0 {
+			var groups []export.CodeSignGroup
+			for i := range macCodeSignGroups {
+				groups = append(groups, &macCodeSignGroups[i])
+			}
+
+			if g.strict && len(groups) > 1 {
+				return "", ExportCodeSigning{}, newMultipleCodeSignGroupsError(groups)
+			}
+
+			selection := g.codeSignGroupSelection(archive.SigningIdentity(), archive.Application.ProvisioningProfile.TeamID, teamID)
+			codeSignGroup := selection.selectGroup(groups)
+
+			exportTeamID = codeSignGroup.Certificate().TeamID
+			exportCertificate = codeSignGroup.Certificate()
+			if installerCertificate := codeSignGroup.InstallerCertificate(); installerCertificate != nil {
+				exportInstallerCertificate = *installerCertificate
+			}
+
+			bundleIDProfileMap := codeSignGroup.BundleIDProfileMap()
+			exportCodeSignStyle = "manual"
+			for _, bundleID := range sortedBundleIDs(bundleIDProfileMap) {
+				profileInfo := bundleIDProfileMap[bundleID]
+				exportProfileMapping[bundleID] = profileInfo.Name
+				exportProfiles[bundleID] = profileInfo
+
+				if profileutil.IsXcodeManaged(profileInfo.Name) {
+					exportCodeSignStyle = "automatic"
+				}
+			}
+
+			firstXcodeManaged := false
+			for i, bundleID := range sortedBundleIDs(bundleIDProfileMap) {
+				xcodeManaged := profileutil.IsXcodeManaged(bundleIDProfileMap[bundleID].Name)
+				if i == 0 {
+					firstXcodeManaged = xcodeManaged
+				} else if xcodeManaged != firstXcodeManaged {
+					if g.strict {
+						return "", ExportCodeSigning{}, mixedSigningStyleError{BundleID: bundleID}
+					}
+					log.Errorf("Both xcode managed and NON xcode managed profiles in code signing group")
+					break
+				}
+			}
+
+			if g.strict && archive.IsXcodeManaged() && exportCodeSignStyle == "manual" {
+				return "", ExportCodeSigning{}, signingStyleSwitchError{}
+			}
+		} else if exportMethod == exportoptions.MethodAppStore && len(codeSignGroups) > 0 {
+			return "", ExportCodeSigning{}, diagnostics.noInstallerCertificateError(exportMethod)
+		} else {
+			return "", ExportCodeSigning{}, diagnostics.noGroupError(exportMethod)
+		}
+	}
+
+	if teamID != "" && exportTeamID == "" {
+		exportTeamID = teamID
+	}
+
+	var exportOpts exportoptions.ExportOptions
+	if exportMethod == exportoptions.MethodAppStore {
+		options := exportoptions.NewAppStoreOptions()
+		options.UploadBitcode = false
+
+		if xcodebuildMajorVersion >= 9 {
+			options.BundleIDProvisioningProfileMapping = exportProfileMapping
+			options.SigningCertificate = exportCertificate.CommonName
+			options.InstallerSigningCertificate = exportInstallerCertificate.CommonName
+			options.TeamID = exportTeamID
+		}
+
+		exportOpts = options
+	} else {
+		options := exportoptions.NewNonAppStoreOptions(exportMethod)
+		options.CompileBitcode = false
+
+		if xcodebuildMajorVersion >= 9 {
+			options.BundleIDProvisioningProfileMapping = exportProfileMapping
+			options.SigningCertificate = exportCertificate.CommonName
+			options.TeamID = exportTeamID
+		}
+
+		exportOpts = options
+	}
+
+	exportOptionsContent, err := exportOpts.String()
+	if err != nil {
+		return "", ExportCodeSigning{}, err
+	}
+
+	return exportOptionsContent, ExportCodeSigning{
+		Method:               exportMethod,
+		TeamID:               exportTeamID,
+		Certificate:          exportCertificate,
+		InstallerCertificate: exportInstallerCertificate,
+		SigningStyle:         exportCodeSignStyle,
+		Profiles:             exportProfiles,
+	}, nil
+}
+
+// exportMacProduct copies the exported .pkg, or the zipped .app to the deploy dir.
+// It returns the path of the product, the env key it belongs to and the deployed files.
+func (s Step) exportMacProduct(export MethodExport, deployDir string, fileNames outputFileNames) (string, string, []string, error) {
+	pkgs, err := filepath.Glob(filepath.Join(export.ExportDir, "*.pkg"))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to collect pkg files, error: %s", err)
+	}
+	apps, err := filepath.Glob(filepath.Join(export.ExportDir, "*.app"))
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to collect app files, error: %s", err)
+	}
+
+	var exportedPath, envKey string
+	if len(pkgs) > 0 {
+		if len(pkgs) > 1 {
+			s.logger.Warnf("More than 1 .pkg file found, using the first one")
+		}
+
+		pkgName := strings.TrimSuffix(filepath.Base(pkgs[0]), ".pkg")
+		exportedPath = filepath.Join(deployDir, fileNames.methodFileName(pkgName, "", ".pkg", export.DistributionMethod))
+		if err := v1command.CopyFile(pkgs[0], exportedPath); err != nil {
+			return "", "", nil, fmt.Errorf("failed to copy (%s) -> (%s), error: %s", pkgs[0], exportedPath, err)
+		}
+		envKey = bitrisePKGPthEnvKey
+	} else if len(apps) > 0 {
+		if len(apps) > 1 {
+			s.logger.Warnf("More than 1 .app found, using the first one")
+		}
+
+		appName := strings.TrimSuffix(filepath.Base(apps[0]), ".app")
+		exportedPath = filepath.Join(deployDir, fileNames.methodFileName(appName, "", ".app.zip", export.DistributionMethod))
+		if err := ziputil.ZipDir(apps[0], exportedPath, false); err != nil {
+			return "", "", nil, fmt.Errorf("failed to zip (%s) -> (%s), error: %s", apps[0], exportedPath, err)
+		}
+		envKey = bitriseAppPthEnvKey
+	} else {
+		return "", "", nil, fmt.Errorf("no pkg or app found in: %s", export.ExportDir)
+	}
+
+	methodEnvKey := s.outputKey(distributionMethodEnvKey(envKey, export.DistributionMethod))
+	if err := s.exportOutputFile(exportedPath, exportedPath, methodEnvKey); err != nil {
+		return "", "", nil, fmt.Errorf("failed to export %s, error: %s", methodEnvKey, err)
+	}
+
+	s.logger.Donef("The %s product path is now available in the Environment Variable: %s (value: %s)", export.DistributionMethod, methodEnvKey, exportedPath)
+
+	return exportedPath, envKey, []string{exportedPath}, nil
+}
+
+func macosArchiveReportTargets(archive v1xcarchive.MacosArchive) []ExportReportTarget {
+	targets := []ExportReportTarget{newExportReportTarget(archive.Application.InfoPlist, archive.Application.ProvisioningProfile)}
+	for _, extension := range archive.Application.Extensions {
+		targets = append(targets, newExportReportTarget(extension.InfoPlist, extension.ProvisioningProfile))
+	}
+	return targets
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+	"howett.net/plist"
+)
+
+func testMacosArchive(profile *profileutil.ProvisioningProfileInfoModel) v1xcarchive.MacosArchive {
+	application := v1xcarchive.MacosApplication{}
+	application.InfoPlist = plistutil.PlistData{"CFBundleIdentifier": testBundleID}
+	application.Entitlements = plistutil.PlistData{}
+	application.ProvisioningProfile = profile
+
+	return v1xcarchive.MacosArchive{
+		Path:        "sample.xcarchive",
+		Application: application,
+	}
+}
+
+func testMacosProfile(name, uuid string, method exportoptions.Method, certificates ...certificateutil.CertificateInfoModel) profileutil.ProvisioningProfileInfoModel {
+	profile := testProfile(name, uuid, method, certificates...)
+	profile.Type = profileutil.ProfileTypeMacOs
+	return profile
+}
+
+func TestGenerateMacExportOptionsPlist_appStore(t *testing.T) {
+	// Given
+	distributionCertificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	installerCertificate := testCertificate("2", "3rd Party Mac Developer Installer: Bitrise Bot (72SA8V3WYL)")
+	profile := testMacosProfile("Sample Mac App Store", "mac-app-store-uuid", exportoptions.MethodAppStore, distributionCertificate)
+	codesignAssets := fakeCodesignAssetProvider{
+		certificates:          []certificateutil.CertificateInfoModel{distributionCertificate},
+		installerCertificates: []certificateutil.CertificateInfoModel{installerCertificate},
+		profiles:              []profileutil.ProvisioningProfileInfoModel{profile, testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, distributionCertificate)},
+	}
+	archive := testMacosArchive(&profile)
+
+	// When
+	content, codeSigning, err := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false).generateMacExportOptionsPlist("app-store", "", 15, archive)
+
+	// Then
+	assert.NoError(t, err)
+
+	var options map[string]interface{}
+	_, err = plist.Unmarshal([]byte(content), &options)
+	assert.NoError(t, err)
+	assert.Equal(t, "app-store", options["method"])
+	assert.Equal(t, installerCertificate.CommonName, options["installerSigningCertificate"])
+	assert.Equal(t, map[string]interface{}{testBundleID: "Sample Mac App Store"}, options["provisioningProfiles"])
+
+	assert.Equal(t, installerCertificate, codeSigning.InstallerCertificate)
+	assert.Equal(t, "mac-app-store-uuid", codeSigning.Profiles[testBundleID].UUID)
+}
+
+func TestGenerateMacExportOptionsPlist_appStoreWithoutInstallerCertificate(t *testing.T) {
+	// Given
+	distributionCertificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	profile := testMacosProfile("Sample Mac App Store", "mac-app-store-uuid", exportoptions.MethodAppStore, distributionCertificate)
+	codesignAssets := fakeCodesignAssetProvider{
+		certificates: []certificateutil.CertificateInfoModel{distributionCertificate},
+		profiles:     []profileutil.ProvisioningProfileInfoModel{profile},
+	}
+	archive := testMacosArchive(&profile)
+
+	// When
+	content, _, err := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false).generateMacExportOptionsPlist("app-store", "", 15, archive)
+
+	// Then
+	assert.EqualError(t, err, "no installer certificate (Mac Installer Distribution) found for the app-store export method, install one to sign the package")
+	assert.Empty(t, content)
+}
+
+func TestGenerateMacExportOptionsPlist_developerIDWithoutProfile(t *testing.T) {
+	// Given
+	archive := testMacosArchive(nil)
+
+	// When
+	content, codeSigning, err := newExportOptionsGenerator(fakeCodesignAssetProvider{}, codeSignGroupSelectionPolicy{}, nil, false).generateMacExportOptionsPlist("developer-id", testTeamID, 15, archive)
+
+	// Then
+	assert.NoError(t, err)
+
+	var options map[string]interface{}
+	_, err = plist.Unmarshal([]byte(content), &options)
+	assert.NoError(t, err)
+	assert.Equal(t, "developer-id", options["method"])
+	assert.Equal(t, testTeamID, options["teamID"])
+	assert.Equal(t, exportoptions.MethodDeveloperID, codeSigning.Method)
+}
+
+func TestIsInstallerCertificate(t *testing.T) {
+	assert.True(t, isInstallerCertificate(testCertificate("1", "Developer ID Installer: Bitrise Bot (72SA8V3WYL)")))
+	assert.False(t, isInstallerCertificate(testCertificate("2", "Developer ID Application: Bitrise Bot (72SA8V3WYL)")))
+}
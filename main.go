@@ -5,9 +5,9 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/bitrise-io/go-steputils/output"
 	"github.com/bitrise-io/go-steputils/v2/stepconf"
 	v1command "github.com/bitrise-io/go-utils/command"
 	"github.com/bitrise-io/go-utils/fileutil"
@@ -29,15 +29,35 @@ import (
 	"github.com/teamlapse/go-xcode/v2/autocodesign/profiledownloader"
 	"github.com/teamlapse/go-xcode/v2/codesign"
 	"github.com/teamlapse/go-xcode/v2/xcarchive"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
 	"github.com/teamlapse/go-xcode/xcodebuild"
 	"howett.net/plist"
 )
 
 const (
 	// Outputs
-	bitriseIPAPthEnvKey                 = "BITRISE_IPA_PATH"
-	bitriseDSYMPthEnvKey                = "BITRISE_DSYM_PATH"
-	bitriseIDEDistributionLogsPthEnvKey = "BITRISE_IDEDISTRIBUTION_LOGS_PATH"
+	bitriseIPAPthEnvKey                   = "BITRISE_IPA_PATH"
+	bitriseIPAPthListEnvKey               = "BITRISE_IPA_PATH_LIST"
+	bitriseIPAMapPthEnvKey                = "BITRISE_IPA_MAP_PATH"
+	bitriseAppPthEnvKey                   = "BITRISE_APP_PATH"
+	bitrisePKGPthEnvKey                   = "BITRISE_PKG_PATH"
+	bitriseDSYMPthEnvKey                  = "BITRISE_DSYM_PATH"
+	bitriseExtensionDSYMPthEnvKey         = "BITRISE_EXTENSION_DSYM_PATH"
+	bitriseFrameworkDSYMPthEnvKey         = "BITRISE_FRAMEWORK_DSYM_PATH"
+	bitriseBCSymbolMapsPthEnvKey          = "BITRISE_BCSYMBOLMAPS_PATH"
+	bitriseSymbolManifestPthEnvKey        = "BITRISE_SYMBOL_MANIFEST_PATH"
+	bitriseDSYMUploadResultsPthEnvKey     = "BITRISE_DSYM_UPLOAD_RESULTS_PATH"
+	bitriseDSYMUploadStatusEnvKey         = "BITRISE_DSYM_UPLOAD_STATUS"
+	bitriseExportDirEnvKey                = "BITRISE_EXPORT_DIR"
+	bitriseExportSummaryPthEnvKey         = "BITRISE_EXPORT_SUMMARY_PATH"
+	bitriseIDEDistributionLogsPthEnvKey   = "BITRISE_IDEDISTRIBUTION_LOGS_PATH"
+	bitriseExportPlanPthEnvKey            = "BITRISE_EXPORT_PLAN_PATH"
+	bitriseExportReportPthEnvKey          = "BITRISE_EXPORT_REPORT_PATH"
+	bitriseOTAManifestPthEnvKey           = "BITRISE_OTA_MANIFEST_PATH"
+	bitriseOTAInstallPagePthEnvKey        = "BITRISE_OTA_INSTALL_PAGE_PATH"
+	bitriseAppThinningSizeReportPthEnvKey = "BITRISE_APP_THINNING_SIZE_REPORT_PATH"
+	bitriseAppThinningVariantsPthEnvKey   = "BITRISE_APP_THINNING_VARIANTS_PATH"
+	bitriseExportFailureReasonEnvKey      = "BITRISE_EXPORT_FAILURE_REASON"
 	// Code Signing Authentication Source
 	codeSignSourceOff     = "off"
 	codeSignSourceAPIKey  = "api-key"
@@ -46,9 +66,10 @@ const (
 
 // Inputs ...
 type Inputs struct {
-	ArchivePath         string `env:"archive_path,dir"`
+	ArchivePath         string `env:"archive_path,required"`
+	ArchiveChecksum     string `env:"archive_checksum"`
 	ProductToDistribute string `env:"product,opt[app,app-clip]"`
-	DistributionMethod  string `env:"distribution_method,opt[development,app-store,ad-hoc,enterprise]"`
+	DistributionMethod  string `env:"distribution_method,required"`
 	// Automatic code signing
 	CodeSigningAuthSource     string          `env:"automatic_code_signing,opt[off,api-key,apple-id]"`
 	CertificateURLList        string          `env:"certificate_url_list"`
@@ -65,60 +86,134 @@ type Inputs struct {
 	CompileBitcode              bool   `env:"compile_bitcode,opt[yes,no]"`
 	UploadBitcode               bool   `env:"upload_bitcode,opt[yes,no]"`
 	ManageVersionAndBuildNumber bool   `env:"manage_version_and_build_number"`
+	TestFlightInternalTesting   bool   `env:"testflight_internal_testing_only,opt[yes,no]"`
+	StripSwiftSymbols           bool   `env:"strip_swift_symbols,opt[yes,no]"`
+	Destination                 string `env:"destination,opt[export,upload]"`
 	ExportOptionsPlistContent   string `env:"export_options_plist_content"`
+	ExportOptionsMode           string `env:"export_options_mode,opt[replace,merge]"`
+	SelectionCriteria           string `env:"code_sign_group_selection_criteria"`
+	PreferredCodeSignAssets     string `env:"preferred_code_sign_assets"`
+	ProfileMapping              string `env:"provisioning_profile_mapping"`
+	Strict                      bool   `env:"strict,opt[yes,no]"`
+	FileNameTemplate            string `env:"output_file_name_template"`
+	// App Thinning
+	Thinning           string `env:"thinning"`
+	ThinningSizeBudget string `env:"thinning_size_budget"`
+	// OTA installation
+	OTABaseURL          string `env:"ota_base_url"`
+	OTADisplayImageURL  string `env:"ota_display_image_url"`
+	OTAFullSizeImageURL string `env:"ota_full_size_image_url"`
+	// Code signing assets
+	CertificatesDir               string          `env:"certificates_dir"`
+	CertificatesDirPassphraseList stepconf.Secret `env:"certificates_dir_passphrase_list"`
+	ProvisioningProfilesDir       string          `env:"provisioning_profiles_dir"`
 	// App Store Connect connection override
 	APIKeyPath     stepconf.Secret `env:"api_key_path"`
 	APIKeyID       string          `env:"api_key_id"`
 	APIKeyIssuerID string          `env:"api_key_issuer_id"`
+	// dSYM export
+	DSYMScope          string `env:"dsym_scope,opt[app,app-and-extensions,all]"`
+	FailOnDSYMMismatch bool   `env:"fail_on_dsym_mismatch,opt[yes,no]"`
+	// dSYM upload
+	DSYMUploadURL        string          `env:"dsym_upload_url"`
+	DSYMUploadTarget     string          `env:"dsym_upload_target,opt[sentry,multipart]"`
+	DSYMUploadContent    string          `env:"dsym_upload_content,opt[zip,dsym]"`
+	DSYMUploadAuthHeader stepconf.Secret `env:"dsym_upload_auth_header"`
+	DSYMUploadRetryMax   int             `env:"dsym_upload_retries,range[0..10]"`
+	// Performance
+	ExportConcurrency int `env:"export_concurrency,range[1..16]"`
 	// Debugging
+	DryRun     bool `env:"dry_run,opt[yes,no]"`
 	VerboseLog bool `env:"verbose_log,opt[yes,no]"`
 	// Output export
 	DeployDir string `env:"BITRISE_DEPLOY_DIR"`
 }
 
 type Config struct {
-	ArchivePath                 string
-	DeployDir                   string
-	ProductToDistribute         ExportProduct
-	ExportOptionsPlistContent   string
-	DistributionMethod          string
-	TeamID                      string
-	UploadBitcode               bool
-	CompileBitcode              bool
-	ManageVersionAndBuildNumber bool
-	XcodebuildVersion           models.XcodebuildVersionModel
-	CodesignManager             *codesign.Manager // nil if automatic code signing is "off"
-	VerboseLog                  bool
+	ArchivePath               string
+	ArchiveTmpDir             string // holds the downloaded or unzipped archive, removed after the export
+	DeployDir                 string
+	ProductToDistribute       ExportProduct
+	ExportOptionsPlistContent string
+	ExportOptionsMode         string
+	DistributionMethods       []string
+	TeamID                    string
+	ExportOptions             exportOptionsConfig
+	XcodebuildVersion         models.XcodebuildVersionModel
+	IsMacOS                   bool
+	Archive                   xcarchive.IosArchive     // set if IsMacOS is false
+	MacosArchive              v1xcarchive.MacosArchive // set if IsMacOS is true
+	CodesignAssets            CodesignAssetProvider
+	SelectionPolicy           codeSignGroupSelectionPolicy
+	ProfileMapping            map[string]string
+	Strict                    bool
+	FileNames                 outputFileNames
+	Thinning                  thinningConfig
+	DSYMScope                 string
+	FailOnDSYMMismatch        bool
+	DSYMUpload                dsymUploadConfig
+	ExportConcurrency         int
+	OTAInstall                otaInstallConfig
+	CodesignManagers          map[string]*codesign.Manager // empty if automatic code signing is "off"
+	DryRun                    bool
+	VerboseLog                bool
 }
 
-type RunOut struct {
+// MethodExport describes the result of exporting the archive with a single distribution method.
+type MethodExport struct {
+	DistributionMethod    string
+	ExportDir             string
 	IDEDistrubutionLogDir string
-	TmpDir                string
-	AppDSYMs              []string
-	ArchiveName           string
+	Plan                  ExportMethodPlan
+	CodeSigning           ExportCodeSigning
+	// Uploaded is true if xcodebuild uploaded the export to App Store Connect, the export dir contains no IPA then.
+	Uploaded bool
+	// ThinnedVariants are the variants of the App Thinning Size Report, empty if the IPAs are not thinned.
+	ThinnedVariants []ThinnedVariant
+}
+
+type RunOut struct {
+	Exports     []MethodExport
+	DSYMs       archiveDSYMs
+	ArchiveName string
 }
 
 type ExportOpts struct {
-	IDEDistrubutionLogDir string
-	TmpDir                string
-	DeployDir             string
-	AppDSYMs              []string
-	ArchiveName           string
+	Exports             []MethodExport
+	DistributionMethods []string
+	DeployDir           string
+	ProductToDistribute ExportProduct
+	DSYMs               archiveDSYMs
+	ArchiveName         string
+	ArchivePath         string
+	IsMacOS             bool
+	Archive             xcarchive.IosArchive
+	MacosArchive        v1xcarchive.MacosArchive
+	XcodebuildVersion   models.XcodebuildVersionModel
+	OTAInstall          otaInstallConfig
+	FileNames           outputFileNames
+	Thinning            thinningConfig
+	FailOnDSYMMismatch  bool
+	DSYMUpload          dsymUploadConfig
+	DryRun              bool
 }
 
 type Step struct {
 	commandFactory command.Factory
 	inputParser    stepconf.InputParser
 	logger         log.Logger
+	// exclusive serializes the stages of the concurrent export jobs which can not run in parallel.
+	exclusive *sync.Mutex
+	// outputSuffix is appended to the env keys of the outputs, the multi-archive export sets it to the archive index (for example: _0).
+	outputSuffix string
 }
 
-func (s Step) ProcessInputs() (Config, error) {
-	var inputs Inputs
-	if err := s.inputParser.Parse(&inputs); err != nil {
-		return Config{}, fmt.Errorf("issue with input: %s", err)
-	}
-
+// ProcessInputs holds the exclusive lock only while it uses the global logger, xcodebuild and the keychain,
+// the archive is downloaded and unzipped concurrently with the other export jobs.
+func (s Step) ProcessInputs(inputs Inputs) (_ Config, err error) {
+	release := s.acquireExclusive()
 	v1log.SetEnableDebugLog(inputs.VerboseLog)
+	release()
 	s.logger.EnableDebugLog(inputs.VerboseLog)
 
 	productToDistribute, err := ParseExportProduct(inputs.ProductToDistribute)
@@ -126,21 +221,96 @@ func (s Step) ProcessInputs() (Config, error) {
 		return Config{}, fmt.Errorf("failed to parse export product option, error: %s", err)
 	}
 
+	archiveChecksum, err := parseArchiveChecksum(inputs.ArchiveChecksum)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse archive checksum option, error: %s", err)
+	}
+
+	archivePath, archiveTmpDir, err := s.prepareArchive(inputs.ArchivePath, archiveChecksum)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to prepare archive, error: %s", err)
+	}
+	defer func() {
+		if err != nil {
+			s.cleanupArchive(archiveTmpDir)
+		}
+	}()
+
+	isMacOS, err := v1xcarchive.IsMacOS(archivePath)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to check if the archive is a macOS archive, error: %s", err)
+	}
+
+	distributionMethods, err := parseDistributionMethods(inputs.DistributionMethod, isMacOS)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse distribution method option, error: %s", err)
+	}
+
+	selectionPolicy, err := parseCodeSignGroupSelectionPolicy(inputs.SelectionCriteria, inputs.PreferredCodeSignAssets)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse code sign group selection criteria option, error: %s", err)
+	}
+
+	profileMapping, err := parseProfileMapping(inputs.ProfileMapping)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse provisioning profile mapping option, error: %s", err)
+	}
+
+	otaInstall, err := parseOTAInstallConfig(inputs.OTABaseURL, inputs.OTADisplayImageURL, inputs.OTAFullSizeImageURL)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse OTA installation options, error: %s", err)
+	}
+
+	fileNameTemplate, err := parseFileNameTemplate(inputs.FileNameTemplate)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse output file name template option, error: %s", err)
+	}
+
+	thinning, err := parseThinningConfig(inputs.Thinning, inputs.ThinningSizeBudget)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse App Thinning options, error: %s", err)
+	}
+
+	dsymUpload, err := parseDSYMUploadConfig(inputs.DSYMUploadURL, inputs.DSYMUploadTarget, inputs.DSYMUploadContent, string(inputs.DSYMUploadAuthHeader), inputs.DSYMUploadRetryMax)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse dSYM upload options, error: %s", err)
+	}
+
+	release = s.acquireExclusive()
 	stepconf.Print(inputs)
-	fmt.Println()
+	release()
+	s.logger.Println()
+
+	if otaInstall.isEnabled() {
+		hasOTAInstallMethod := false
+		for _, distributionMethod := range distributionMethods {
+			if isOTAInstallMethod(distributionMethod) {
+				hasOTAInstallMethod = true
+			}
+		}
+		if isMacOS || !hasOTAInstallMethod {
+			s.logger.Warnf("OTA installation is supported for ad-hoc and enterprise iOS exports, OTA base URL is ignored")
+		}
+	}
 
 	trimmedExportOptions := strings.TrimSpace(inputs.ExportOptionsPlistContent)
 	if inputs.ExportOptionsPlistContent != trimmedExportOptions {
 		inputs.ExportOptionsPlistContent = trimmedExportOptions
 		s.logger.Warnf("ExportOptionsPlistContent contains leading and trailing white space, removed:")
 		s.logger.Printf(inputs.ExportOptionsPlistContent)
-		fmt.Println()
+		s.logger.Println()
 	}
 	if inputs.ExportOptionsPlistContent != "" {
 		var options map[string]interface{}
 		if _, err := plist.Unmarshal([]byte(inputs.ExportOptionsPlistContent), &options); err != nil {
 			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: %s", err.Error())
 		}
+		if err := validateProvidedThinning(thinning, inputs.ExportOptionsMode, options); err != nil {
+			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: %s", err)
+		}
+		if err := validateProvidedMethod(inputs.ExportOptionsMode, distributionMethods, options); err != nil {
+			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: %s", err)
+		}
 	}
 
 	trimmedTeamID := strings.TrimSpace(inputs.TeamID)
@@ -151,36 +321,150 @@ func (s Step) ProcessInputs() (Config, error) {
 
 	s.logger.Infof("Step determined configs:")
 
+	release = s.acquireExclusive()
 	xcodebuildVersion, err := utility.GetXcodeVersion()
+	release()
 	if err != nil {
 		return Config{}, fmt.Errorf("failed to determine Xcode version, error: %s", err)
 	}
 	s.logger.Printf("- xcodebuildVersion: %s (%s)", xcodebuildVersion.Version, xcodebuildVersion.BuildVersion)
 
-	var codesignManager *codesign.Manager
-	if inputs.CodeSigningAuthSource != codeSignSourceOff {
-		manager, err := s.createCodesignManager(inputs, int(xcodebuildVersion.MajorVersion))
+	if inputs.ExportOptionsPlistContent != "" {
+		issues, err := validateExportOptions(inputs.ExportOptionsPlistContent, xcodebuildVersion)
 		if err != nil {
-			return Config{}, err
+			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent: %s", err)
+		}
+
+		var errs []string
+		for _, issue := range issues {
+			if issue.IsError {
+				errs = append(errs, issue.String())
+			} else {
+				s.logger.Warnf("ExportOptionsPlistContent: %s", issue)
+			}
+		}
+		if len(errs) > 0 {
+			return Config{}, fmt.Errorf("issue with input ExportOptionsPlistContent:\n%s", strings.Join(errs, "\n"))
+		}
+	}
+
+	s.logger.Printf("- distributionMethods: %s", strings.Join(distributionMethods, ", "))
+
+	s.logger.Printf("- isMacOS: %v", isMacOS)
+
+	var archive xcarchive.IosArchive
+	var macosArchive v1xcarchive.MacosArchive
+	if isMacOS {
+		if productToDistribute == ExportProductAppClip {
+			return Config{}, fmt.Errorf("exporting an App Clip is not supported for macOS archives")
 		}
-		codesignManager = &manager
+		if len(profileMapping) > 0 {
+			s.logger.Warnf("Provisioning profile mapping is not supported for macOS archives, ignoring it")
+		}
+		if thinning.isEnabled() {
+			s.logger.Warnf("App Thinning is not supported for macOS archives, ignoring it")
+			thinning = thinningConfig{}
+		}
+
+		macosArchive, err = v1xcarchive.NewMacosArchive(archivePath)
+	} else {
+		archive, err = xcarchive.NewIosArchive(archivePath)
+	}
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to parse archive, error: %s", err)
+	}
+
+	var fileNameValues map[string]string
+	if isMacOS {
+		fileNameValues = macosArchiveFileNameValues(macosArchive, productToDistribute, time.Now())
+	} else {
+		fileNameValues = iosArchiveFileNameValues(archive, productToDistribute, time.Now())
+	}
+	fileNames := newOutputFileNames(fileNameTemplate, distributionMethods, fileNameValues)
+	if fileNameTemplate != "" {
+		s.logger.Printf("- outputFileName: %s", fileNames.resolve("<method>"))
+	}
+
+	var codesignAssets CodesignAssetProvider
+	if inputs.CertificatesDir != "" || inputs.ProvisioningProfilesDir != "" {
+		s.logger.Printf("- codesignAssets: certificates from %s, provisioning profiles from %s", inputs.CertificatesDir, inputs.ProvisioningProfilesDir)
+		passphrases := strings.Split(string(inputs.CertificatesDirPassphraseList), "|")
+		codesignAssets = NewDirectoryCodesignAssetProvider(inputs.CertificatesDir, passphrases, inputs.ProvisioningProfilesDir)
+	} else {
+		s.logger.Printf("- codesignAssets: installed certificates and provisioning profiles")
+		codesignAssets = NewLocalCodesignAssetProvider()
+	}
+
+	if inputs.CodeSigningAuthSource != codeSignSourceOff && isMacOS {
+		return Config{}, fmt.Errorf("automatic code signing is not supported for macOS archives, set automatic_code_signing to off")
+	}
+	release = s.acquireExclusive()
+	codesignManagers, err := s.createCodesignManagers(inputs, distributionMethods, archive, int(xcodebuildVersion.MajorVersion))
+	release()
+	if err != nil {
+		return Config{}, err
 	}
 
 	return Config{
-		ArchivePath:               inputs.ArchivePath,
+		ArchivePath:               archivePath,
+		ArchiveTmpDir:             archiveTmpDir,
 		DeployDir:                 inputs.DeployDir,
 		ProductToDistribute:       productToDistribute,
 		ExportOptionsPlistContent: inputs.ExportOptionsPlistContent,
-		DistributionMethod:        inputs.DistributionMethod,
+		ExportOptionsMode:         inputs.ExportOptionsMode,
+		DistributionMethods:       distributionMethods,
 		TeamID:                    inputs.TeamID,
-		UploadBitcode:             inputs.UploadBitcode,
-		CompileBitcode:            inputs.CompileBitcode,
-		XcodebuildVersion:         xcodebuildVersion,
-		CodesignManager:           codesignManager,
+		ExportOptions: exportOptionsConfig{
+			Product:                     productToDistribute,
+			TeamID:                      inputs.TeamID,
+			UploadBitcode:               inputs.UploadBitcode,
+			CompileBitcode:              inputs.CompileBitcode,
+			XcodebuildVersion:           xcodebuildVersion,
+			ManageVersionAndBuildNumber: inputs.ManageVersionAndBuildNumber,
+			TestFlightInternalTesting:   inputs.TestFlightInternalTesting,
+			StripSwiftSymbols:           inputs.StripSwiftSymbols,
+			Destination:                 inputs.Destination,
+			Thinning:                    thinning.Thinning,
+		},
+		XcodebuildVersion:  xcodebuildVersion,
+		IsMacOS:            isMacOS,
+		Archive:            archive,
+		MacosArchive:       macosArchive,
+		CodesignAssets:     codesignAssets,
+		SelectionPolicy:    selectionPolicy,
+		ProfileMapping:     profileMapping,
+		Strict:             inputs.Strict,
+		FileNames:          fileNames,
+		Thinning:           thinning,
+		DSYMScope:          inputs.DSYMScope,
+		FailOnDSYMMismatch: inputs.FailOnDSYMMismatch,
+		DSYMUpload:         dsymUpload,
+		ExportConcurrency:  inputs.ExportConcurrency,
+		OTAInstall:         otaInstall,
+		CodesignManagers:   codesignManagers,
+		DryRun:             inputs.DryRun,
 	}, nil
 }
 
-func (s Step) createCodesignManager(inputs Inputs, xcodeMajorVersion int) (codesign.Manager, error) {
+// createCodesignManagers creates the automatic code signing manager of every distribution method,
+// it returns an empty map if automatic code signing is off.
+func (s Step) createCodesignManagers(inputs Inputs, distributionMethods []string, a xcarchive.IosArchive, xcodeMajorVersion int) (map[string]*codesign.Manager, error) {
+	codesignManagers := map[string]*codesign.Manager{}
+	if inputs.CodeSigningAuthSource == codeSignSourceOff {
+		return codesignManagers, nil
+	}
+
+	for _, distributionMethod := range distributionMethods {
+		manager, err := s.createCodesignManager(inputs, distributionMethod, a, xcodeMajorVersion)
+		if err != nil {
+			return nil, err
+		}
+		codesignManagers[distributionMethod] = &manager
+	}
+	return codesignManagers, nil
+}
+
+func (s Step) createCodesignManager(inputs Inputs, distributionMethod string, a xcarchive.IosArchive, xcodeMajorVersion int) (codesign.Manager, error) {
 	var authType codesign.AuthType
 	switch inputs.CodeSigningAuthSource {
 	case codeSignSourceAppleID:
@@ -191,9 +475,14 @@ func (s Step) createCodesignManager(inputs Inputs, xcodeMajorVersion int) (codes
 		return codesign.Manager{}, fmt.Errorf("automatic code signing is disabled")
 	}
 
+	exportMethod, err := parseExportMethod(distributionMethod)
+	if err != nil {
+		return codesign.Manager{}, fmt.Errorf("issue with input: %s", err)
+	}
+
 	codesignInputs := codesign.Input{
 		AuthType:                  authType,
-		DistributionMethod:        inputs.DistributionMethod,
+		DistributionMethod:        string(exportMethod),
 		CertificateURLList:        inputs.CertificateURLList,
 		CertificatePassphraseList: inputs.CertificatePassphraseList,
 		KeychainPath:              inputs.KeychainPath,
@@ -205,10 +494,6 @@ func (s Step) createCodesignManager(inputs Inputs, xcodeMajorVersion int) (codes
 		return codesign.Manager{}, fmt.Errorf("issue with input: %s", err)
 	}
 
-	a, err := xcarchive.NewIosArchive(inputs.ArchivePath)
-	if err != nil {
-		return codesign.Manager{}, err
-	}
 	archive := codesign.NewArchive(a)
 
 	var serviceConnection *devportalservice.AppleDeveloperConnection = nil
@@ -267,19 +552,115 @@ func (s Step) createCodesignManager(inputs Inputs, xcodeMajorVersion int) (codes
 }
 
 func (s Step) Run(opts Config) (RunOut, error) {
+	archiveExt := filepath.Ext(opts.ArchivePath)
+	archiveName := filepath.Base(opts.ArchivePath)
+	archiveName = strings.TrimSuffix(archiveName, archiveExt)
+
+	envsToUnset := []string{"GEM_HOME", "GEM_PATH", "RUBYLIB", "RUBYOPT", "BUNDLE_BIN_PATH", "_ORIGINAL_GEM_PATH", "BUNDLE_GEMFILE"}
+	for _, key := range envsToUnset {
+		if err := os.Unsetenv(key); err != nil {
+			return RunOut{}, fmt.Errorf("failed to unset (%s), error: %s", key, err)
+		}
+	}
+
+	var archiveProfile profileutil.ProvisioningProfileInfoModel
+	if opts.IsMacOS {
+		if opts.MacosArchive.Application.ProvisioningProfile != nil {
+			archiveProfile = *opts.MacosArchive.Application.ProvisioningProfile
+		}
+	} else {
+		archiveProfile = opts.Archive.Application.ProvisioningProfile
+	}
+	archiveExportMethod := archiveProfile.ExportType
+	archiveCodeSignIsXcodeManaged := profileutil.IsXcodeManaged(archiveProfile.Name)
+
+	if opts.ProductToDistribute == ExportProductAppClip {
+		if opts.XcodebuildVersion.MajorVersion < 12 {
+			return RunOut{}, fmt.Errorf("exporting an App Clip requires Xcode 12 or a later version")
+		}
+
+		if opts.Archive.Application.ClipApplication == nil {
+			return RunOut{}, fmt.Errorf("failed to export App Clip, error: xcarchive does not contain an App Clip")
+		}
+	}
+
+	s.logger.Println()
+	s.logger.Infof("Archive info:")
+	if opts.IsMacOS {
+		s.logger.Printf("platform: macOS")
+		s.logger.Printf("signing identity: %s", opts.MacosArchive.SigningIdentity())
+	}
+	s.logger.Printf("team: %s (%s)", archiveProfile.TeamName, archiveProfile.TeamID)
+	s.logger.Printf("profile: %s (%s)", archiveProfile.Name, archiveProfile.UUID)
+	s.logger.Printf("export: %s", archiveExportMethod)
+	s.logger.Printf("Xcode managed profile: %v", archiveCodeSignIsXcodeManaged)
+	s.logger.Println()
+
+	// The outputs of the exported methods are finished even if other methods failed
+	exports, exportErr := s.exportMethods(opts)
+	if exportErr != nil && !hasCompletedExport(exports) {
+		return RunOut{
+			Exports: exports,
+		}, exportErr
+	}
+
+	var appDSYMs, otherDSYMs []string
+	var err error
+	if opts.IsMacOS {
+		appDSYMs, otherDSYMs, err = opts.MacosArchive.FindDSYMs()
+	} else {
+		appDSYMs, otherDSYMs, err = opts.Archive.FindDSYMs()
+	}
+	if err != nil {
+		return RunOut{}, fmt.Errorf("failed to export dsym, error: %s", err)
+	}
+
+	dsyms, err := collectDSYMs(opts.ArchivePath, appDSYMs, otherDSYMs, opts.DSYMScope)
+	if err != nil {
+		return RunOut{}, fmt.Errorf("failed to export dsym, error: %s", err)
+	}
+	if opts.IsMacOS {
+		dsyms.Binaries = macosArchiveBinaries(opts.MacosArchive, opts.DSYMScope)
+	} else {
+		dsyms.Binaries = iosArchiveBinaries(opts.Archive, opts.DSYMScope)
+	}
+
+	return RunOut{
+		Exports:     exports,
+		DSYMs:       dsyms,
+		ArchiveName: archiveName,
+	}, exportErr
+}
+
+func hasCompletedExport(exports []MethodExport) bool {
+	for _, export := range exports {
+		if export.ExportDir != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func (s Step) exportArchive(opts Config, distributionMethod string) (MethodExport, error) {
+	// Code signing and export options generation use the keychain and the global logger
+	release := s.acquireExclusive()
+	defer release()
+
+	s.logger.Infof("Exporting archive with distribution method: %s", distributionMethod)
+
 	var authOptions *xcodebuild.AuthenticationParams = nil
-	if opts.CodesignManager != nil {
+	if codesignManager := opts.CodesignManagers[distributionMethod]; codesignManager != nil {
 		s.logger.Infof("Preparing code signing assets (certificates, profiles)")
 
-		xcodebuildAuthParams, err := opts.CodesignManager.PrepareCodesigning()
+		xcodebuildAuthParams, err := codesignManager.PrepareCodesigning()
 		if err != nil {
-			return RunOut{}, fmt.Errorf("failed to manage code signing: %s", err)
+			return MethodExport{}, fmt.Errorf("failed to manage code signing: %s", err)
 		}
 
 		if xcodebuildAuthParams != nil {
 			privateKey, err := xcodebuildAuthParams.WritePrivateKeyToFile()
 			if err != nil {
-				return RunOut{}, err
+				return MethodExport{}, err
 			}
 
 			defer func() {
@@ -297,74 +678,110 @@ func (s Step) Run(opts Config) (RunOut, error) {
 	} else {
 		s.logger.Infof("Automatic code signing is disabled, skipped downloading code sign assets")
 	}
-	fmt.Println()
+	s.logger.Println()
 
-	archiveExt := filepath.Ext(opts.ArchivePath)
-	archiveName := filepath.Base(opts.ArchivePath)
-	archiveName = strings.TrimSuffix(archiveName, archiveExt)
-	exportOptionsPath := filepath.Join(opts.DeployDir, "export_options.plist")
+	exportOptionsPath := filepath.Join(opts.DeployDir, opts.FileNames.methodFileName("export_options", ".export_options", ".plist", distributionMethod))
 
-	envsToUnset := []string{"GEM_HOME", "GEM_PATH", "RUBYLIB", "RUBYOPT", "BUNDLE_BIN_PATH", "_ORIGINAL_GEM_PATH", "BUNDLE_GEMFILE"}
-	for _, key := range envsToUnset {
-		if err := os.Unsetenv(key); err != nil {
-			return RunOut{}, fmt.Errorf("failed to unset (%s), error: %s", key, err)
-		}
-	}
+	s.logger.Infof("Exporting with export options...")
 
-	archive, err := xcarchive.NewIosArchive(opts.ArchivePath)
-	if err != nil {
-		return RunOut{}, fmt.Errorf("failed to parse archive, error: %s", err)
-	}
+	var codeSigning ExportCodeSigning
+	var uploads bool
+	if opts.ExportOptionsPlistContent != "" && opts.ExportOptionsMode != exportOptionsModeMerge {
+		s.logger.Printf("Export options content provided, using it:")
+		s.logger.Printf("%s", opts.ExportOptionsPlistContent)
 
-	mainApplication := archive.Application
-	archiveExportMethod := mainApplication.ProvisioningProfile.ExportType
-	archiveCodeSignIsXcodeManaged := profileutil.IsXcodeManaged(mainApplication.ProvisioningProfile.Name)
+		exportOptionsContent, err := convertExportOptionsMethod(opts.ExportOptionsPlistContent, opts.XcodebuildVersion)
+		if err != nil {
+			return MethodExport{}, err
+		}
 
-	if opts.ProductToDistribute == ExportProductAppClip {
-		if opts.XcodebuildVersion.MajorVersion < 12 {
-			return RunOut{}, fmt.Errorf("exporting an App Clip requires Xcode 12 or a later version")
+		if err := fileutil.WriteStringToFile(exportOptionsPath, exportOptionsContent); err != nil {
+			return MethodExport{}, fmt.Errorf("failed to write export options to file, error: %s", err)
 		}
 
-		if archive.Application.ClipApplication == nil {
-			return RunOut{}, fmt.Errorf("failed to export App Clip, error: xcarchive does not contain an App Clip")
+		providedCodeSigning, err := codeSigningFromExportOptions(opts.ExportOptionsPlistContent)
+		if err != nil {
+			return MethodExport{}, err
 		}
-	}
+		codeSigning = providedCodeSigning
+		uploads = exportOptionsUploads(exportOptionsContent)
+	} else {
+		generator := newExportOptionsGenerator(opts.CodesignAssets, opts.SelectionPolicy, opts.ProfileMapping, opts.Strict)
 
-	fmt.Println()
-	s.logger.Infof("Archive info:")
-	s.logger.Printf("team: %s (%s)", mainApplication.ProvisioningProfile.TeamName, mainApplication.ProvisioningProfile.TeamID)
-	s.logger.Printf("profile: %s (%s)", mainApplication.ProvisioningProfile.Name, mainApplication.ProvisioningProfile.UUID)
-	s.logger.Printf("export: %s", archiveExportMethod)
-	s.logger.Printf("Xcode managed profile: %v", archiveCodeSignIsXcodeManaged)
-	fmt.Println()
+		var exportOptionsContent string
+		var generatedCodeSigning ExportCodeSigning
+		var err error
+		if opts.IsMacOS {
+			exportOptionsContent, generatedCodeSigning, err = generator.generateMacExportOptionsPlist(distributionMethod, opts.TeamID, opts.XcodebuildVersion.MajorVersion, opts.MacosArchive)
+		} else {
+			exportOptionsContent, generatedCodeSigning, err = generator.generateExportOptionsPlist(distributionMethod, opts.Archive, opts.ExportOptions)
+		}
+		if err != nil {
+			return MethodExport{}, fmt.Errorf("failed to generate export options, error: %s", err)
+		}
 
-	s.logger.Infof("Exporting with export options...")
+		s.logger.Printf("\ngenerated export options content:\n%s", exportOptionsContent)
 
-	if opts.ExportOptionsPlistContent != "" {
-		s.logger.Printf("Export options content provided, using it:")
-		fmt.Println(opts.ExportOptionsPlistContent)
+		if opts.ExportOptionsPlistContent != "" {
+			mergedContent, changes, err := mergeExportOptions(exportOptionsContent, opts.ExportOptionsPlistContent)
+			if err != nil {
+				return MethodExport{}, err
+			}
 
-		if err := fileutil.WriteStringToFile(exportOptionsPath, opts.ExportOptionsPlistContent); err != nil {
-			return RunOut{}, fmt.Errorf("failed to write export options to file, error: %s", err)
+			providedCodeSigning, err := codeSigningFromExportOptions(opts.ExportOptionsPlistContent)
+			if err != nil {
+				return MethodExport{}, err
+			}
+			generatedCodeSigning = mergeCodeSigning(generatedCodeSigning, providedCodeSigning)
+
+			s.logger.Println()
+			if len(changes) > 0 {
+				s.logger.Warnf("Export options content provided, merged it on top of the generated export options:")
+				for _, change := range changes {
+					s.logger.Printf(change.String())
+				}
+			} else {
+				s.logger.Printf("Export options content provided, it does not change the generated export options")
+			}
+
+			s.logger.Printf("\nmerged export options content:\n%s", mergedContent)
+			exportOptionsContent = mergedContent
 		}
-	} else {
-		exportOptionsContent, err := generateExportOptionsPlist(opts.ProductToDistribute, opts.DistributionMethod, opts.TeamID, opts.UploadBitcode, opts.CompileBitcode, opts.XcodebuildVersion.MajorVersion, archive, opts.ManageVersionAndBuildNumber)
+
+		exportOptionsContent, err = convertExportOptionsMethod(exportOptionsContent, opts.XcodebuildVersion)
 		if err != nil {
-			return RunOut{}, fmt.Errorf("failed to generate export options, error: %s", err)
+			return MethodExport{}, err
 		}
 
-		s.logger.Printf("\ngenerated export options content:\n%s", exportOptionsContent)
-
 		if err := fileutil.WriteStringToFile(exportOptionsPath, exportOptionsContent); err != nil {
-			return RunOut{}, fmt.Errorf("failed to write export options to file, error: %s", err)
+			return MethodExport{}, fmt.Errorf("failed to write export options to file, error: %s", err)
 		}
+		codeSigning = generatedCodeSigning
+		uploads = exportOptionsUploads(exportOptionsContent)
+
+		s.logger.Println()
+	}
+
+	bundleIDEntitlementsMap := opts.Archive.BundleIDEntitlementsMap()
+	if opts.IsMacOS {
+		bundleIDEntitlementsMap = opts.MacosArchive.BundleIDEntitlementsMap()
+	}
+	plan := newExportMethodPlan(distributionMethod, exportOptionsPath, bundleIDEntitlementsMap, codeSigning)
+
+	if opts.DryRun {
+		s.logger.Warnf("Dry run, skipping the export with %s distribution method", distributionMethod)
+		s.logger.Println()
 
-		fmt.Println()
+		return MethodExport{
+			DistributionMethod: distributionMethod,
+			Plan:               plan,
+			CodeSigning:        codeSigning,
+		}, nil
 	}
 
 	tmpDir, err := pathutil.NormalizedOSTempDirPath("__export__")
 	if err != nil {
-		return RunOut{}, fmt.Errorf("failed to create tmp dir, error: %s", err)
+		return MethodExport{}, fmt.Errorf("failed to create tmp dir, error: %s", err)
 	}
 
 	exportCmd := xcodebuild.NewExportCommand()
@@ -376,10 +793,16 @@ func (s Step) Run(opts Config) (RunOut, error) {
 	}
 
 	s.logger.Donef("$ %s", exportCmd.PrintableCmd())
-	fmt.Println()
+	s.logger.Println()
+
+	release()
+	xcodebuildOut, err := exportCmd.RunAndReturnOutput()
+	if err != nil {
+		release := s.acquireExclusive()
+		defer release()
+
+		var ideDistrubutionLogDir string
 
-	var ideDistrubutionLogDir string
-	if xcodebuildOut, err := exportCmd.RunAndReturnOutput(); err != nil {
 		// xcdistributionlogs
 		if logsDirPth, err := findIDEDistrubutionLogsPath(xcodebuildOut); err != nil {
 			s.logger.Warnf("Failed to find xcdistributionlogs, error: %s", err)
@@ -390,83 +813,416 @@ The logs directory will be stored in $BITRISE_DEPLOY_DIR, and its full path
 will be available in the $BITRISE_IDEDISTRIBUTION_LOGS_PATH environment variable`)
 		}
 
-		return RunOut{
+		failure := classifyExportFailure(xcodebuildOut, ideDistrubutionLogDir)
+		s.logExportFailure(failure)
+
+		return MethodExport{
+			DistributionMethod:    distributionMethod,
 			IDEDistrubutionLogDir: ideDistrubutionLogDir,
-		}, fmt.Errorf("export failed, error: %s", err)
+			Plan:                  plan,
+			CodeSigning:           codeSigning,
+		}, exportFailureError{Failure: failure, Err: err}
 	}
-
-	appDSYMs, _, err := archive.FindDSYMs()
-	if err != nil {
-		return RunOut{}, fmt.Errorf("failed to export dsym, error: %s", err)
+	s.logger.Println()
+
+	var thinnedVariants []ThinnedVariant
+	if opts.Thinning.isEnabled() && isThinningMethod(distributionMethod) {
+		if thinnedVariants, err = readThinningSizeReport(tmpDir); err != nil {
+			return MethodExport{}, err
+		} else if thinnedVariants == nil {
+			s.logger.Warnf("No App Thinning Size Report found in the %s export", distributionMethod)
+		}
 	}
 
-	return RunOut{
-		IDEDistrubutionLogDir: ideDistrubutionLogDir,
-		TmpDir:                tmpDir,
-		AppDSYMs:              appDSYMs,
-		ArchiveName:           archiveName,
+	return MethodExport{
+		DistributionMethod: distributionMethod,
+		ExportDir:          tmpDir,
+		Uploaded:           uploads,
+		ThinnedVariants:    thinnedVariants,
+		Plan:               plan,
+		CodeSigning:        codeSigning,
 	}, nil
 }
 
 func (s Step) ExportOutput(opts ExportOpts) error {
-	if opts.IDEDistrubutionLogDir != "" {
-		ideDistributionLogsZipPath := filepath.Join(opts.DeployDir, "xcodebuild.xcdistributionlogs.zip")
-		if err := output.ZipAndExportOutput([]string{opts.IDEDistrubutionLogDir}, ideDistributionLogsZipPath, bitriseIDEDistributionLogsPthEnvKey); err != nil {
-			s.logger.Warnf("Failed to export %s, error: %s", bitriseIDEDistributionLogsPthEnvKey, err)
+	if opts.DryRun {
+		return s.exportPlan(opts)
+	}
+
+	var report ExportReport
+	if opts.IsMacOS {
+		report = newExportReport(opts.ArchivePath, opts.MacosArchive.InfoPlist, macosArchiveReportTargets(opts.MacosArchive), opts.XcodebuildVersion)
+	} else {
+		report = newExportReport(opts.ArchivePath, opts.Archive.InfoPlist, iosArchiveReportTargets(opts.Archive), opts.XcodebuildVersion)
+	}
+
+	var exportedIPAPaths []string
+	var allDeployedIPAs []ExportedIPA
+	var thinningReports []ThinningSizeReport
+	exportedMacEnvKeys := map[string]bool{}
+	exportedOTAInstall := false
+	var failedExports []MethodExport
+	for _, export := range opts.Exports {
+		if export.IDEDistrubutionLogDir != "" {
+			failedExports = append(failedExports, export)
+			continue
+		}
+		if export.ExportDir == "" {
+			continue
 		}
 
-		return nil
+		if opts.IsMacOS {
+			exportedPath, envKey, deployedPaths, err := s.exportMacProduct(export, opts.DeployDir, opts.FileNames)
+			if err != nil {
+				return err
+			}
+
+			if err := report.addExport(export, deployedPaths); err != nil {
+				return err
+			}
+
+			if !exportedMacEnvKeys[envKey] {
+				exportedMacEnvKeys[envKey] = true
+				envKey = s.outputKey(envKey)
+				if err := s.exportOutputFile(exportedPath, exportedPath, envKey); err != nil {
+					return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+				}
+
+				s.logger.Donef("The product path is now available in the Environment Variable: %s (value: %s)", envKey, exportedPath)
+			}
+			continue
+		}
+
+		if export.Uploaded {
+			s.logger.Printf("The %s export was uploaded to App Store Connect, no ipa to verify and deploy", export.DistributionMethod)
+			if err := report.addExport(export, nil); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if err := s.verifyExportedIPAs(export, opts.Archive); err != nil {
+			return err
+		}
+
+		exportedIPAPath, deployedIPAs, err := s.exportIPA(export, opts.DeployDir, opts.FileNames, exportedProductBundleID(opts.Archive, opts.ProductToDistribute))
+		if err != nil {
+			return err
+		}
+		allDeployedIPAs = append(allDeployedIPAs, deployedIPAs...)
+		deployedIPAPaths := ipaPaths(deployedIPAs)
+
+		if len(export.ThinnedVariants) > 0 {
+			variantsZipPath, err := s.exportThinnedVariants(export, opts.DeployDir, opts.FileNames)
+			if err != nil {
+				return err
+			}
+
+			if variantsZipPath != "" {
+				if len(thinningReports) == 0 {
+					envKey := s.outputKey(bitriseAppThinningVariantsPthEnvKey)
+					if err := s.exportOutputFile(variantsZipPath, variantsZipPath, envKey); err != nil {
+						return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+					}
+
+					s.logger.Donef("The thinned variants zip path is now available in the Environment Variable: %s (value: %s)", envKey, variantsZipPath)
+				}
+				deployedIPAPaths = append(deployedIPAPaths, variantsZipPath)
+			}
+
+			thinningReports = append(thinningReports, ThinningSizeReport{
+				DistributionMethod: export.DistributionMethod,
+				Variants:           export.ThinnedVariants,
+			})
+		}
+
+		if opts.OTAInstall.isEnabled() && isOTAInstallMethod(export.DistributionMethod) {
+			otaPaths, err := s.exportOTAInstall(opts.OTAInstall, export, exportedIPAPath, exportedProductInfoPlist(opts.Archive, opts.ProductToDistribute), opts.DeployDir, opts.FileNames)
+			if err != nil {
+				return err
+			}
+
+			if !exportedOTAInstall {
+				envKeys := []string{s.outputKey(bitriseOTAManifestPthEnvKey), s.outputKey(bitriseOTAInstallPagePthEnvKey)}
+				for i, envKey := range envKeys {
+					if err := s.exportOutputFile(otaPaths[i], otaPaths[i], envKey); err != nil {
+						return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+					}
+				}
+				exportedOTAInstall = true
+
+				s.logger.Donef("The OTA manifest and install page paths are now available in the Environment Variables: %s, %s", envKeys[0], envKeys[1])
+			}
+
+			deployedIPAPaths = append(deployedIPAPaths, otaPaths...)
+		}
+
+		if err := report.addExport(export, deployedIPAPaths); err != nil {
+			return err
+		}
+
+		if len(exportedIPAPaths) == 0 {
+			envKey := s.outputKey(bitriseIPAPthEnvKey)
+			if err := s.exportOutputFile(exportedIPAPath, exportedIPAPath, envKey); err != nil {
+				return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+			}
+
+			s.logger.Donef("The ipa path is now available in the Environment Variable: %s (value: %s)", envKey, exportedIPAPath)
+		}
+		exportedIPAPaths = append(exportedIPAPaths, exportedIPAPath)
 	}
 
-	exportedIPAPath := ""
-	pattern := filepath.Join(opts.TmpDir, "*.ipa")
-	ipas, err := filepath.Glob(pattern)
-	if err != nil {
-		return fmt.Errorf("failed to collect ipa files, error: %s", err)
+	if len(allDeployedIPAs) > 0 {
+		ipaPathList := strings.Join(ipaPaths(allDeployedIPAs), "|")
+		envKey := s.outputKey(bitriseIPAPthListEnvKey)
+		if err := s.exportEnv(envKey, ipaPathList); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+		}
+
+		s.logger.Donef("The ipa path list is now available in the Environment Variable: %s (value: %s)", envKey, ipaPathList)
+
+		if err := s.exportIPAMap(opts.DeployDir, allDeployedIPAs); err != nil {
+			return err
+		}
 	}
 
-	if len(ipas) == 0 {
-		return fmt.Errorf("no ipa found with pattern: %s", pattern)
-	} else if len(ipas) == 1 {
-		exportedIPAPath = filepath.Join(opts.DeployDir, filepath.Base(ipas[0]))
-		if err := v1command.CopyFile(ipas[0], exportedIPAPath); err != nil {
-			return fmt.Errorf("failed to copy (%s) -> (%s), error: %s", ipas[0], exportedIPAPath, err)
+	if len(thinningReports) > 0 {
+		if err := s.exportThinningSizeReports(opts.DeployDir, thinningReports); err != nil {
+			return err
 		}
+	}
+
+	s.exportIDEDistributionLogs(opts, failedExports)
+
+	// The archive outputs are finished if at least one distribution method was exported
+	if len(failedExports) > 0 && len(failedExports) == len(opts.Exports) {
+		return nil
+	}
+
+	if len(opts.Exports) == 0 {
+		return fmt.Errorf("no export found")
+	}
+
+	var dsymZipPaths []string
+	if len(opts.DSYMs.App) == 0 {
+		s.logger.Warnf("No dSYM was found in the archive")
 	} else {
-		s.logger.Warnf("More than 1 .ipa file found")
+		dsymZipPath := filepath.Join(opts.DeployDir, opts.FileNames.archiveFileName(opts.ArchiveName, "", ".dSYM.zip"))
+		envKey := s.outputKey(bitriseDSYMPthEnvKey)
+		if err := s.zipAndExportOutput(opts.DSYMs.App, dsymZipPath, envKey); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+		}
+		dsymZipPaths = append(dsymZipPaths, dsymZipPath)
+
+		s.logger.Donef("The dSYM zip path is now available in the Environment Variable: %s (value: %s)", envKey, dsymZipPath)
+	}
+
+	for _, category := range []struct {
+		pths    []string
+		zipName string
+		envKey  string
+	}{
+		{opts.DSYMs.Extension, opts.FileNames.archiveFileName(opts.ArchiveName, "", ".extensions.dSYM.zip"), s.outputKey(bitriseExtensionDSYMPthEnvKey)},
+		{opts.DSYMs.Framework, opts.FileNames.archiveFileName(opts.ArchiveName, "", ".frameworks.dSYM.zip"), s.outputKey(bitriseFrameworkDSYMPthEnvKey)},
+		{opts.DSYMs.BCSymbolMaps, opts.FileNames.archiveFileName(opts.ArchiveName, "", ".BCSymbolMaps.zip"), s.outputKey(bitriseBCSymbolMapsPthEnvKey)},
+	} {
+		if len(category.pths) == 0 {
+			continue
+		}
+
+		zipPath := filepath.Join(opts.DeployDir, category.zipName)
+		if err := s.zipAndExportOutput(category.pths, zipPath, category.envKey); err != nil {
+			return fmt.Errorf("failed to export %s, error: %s", category.envKey, err)
+		}
+		dsymZipPaths = append(dsymZipPaths, zipPath)
+
+		s.logger.Donef("The %s path is now available in the Environment Variable: %s (value: %s)", category.zipName, category.envKey, zipPath)
+	}
+
+	manifest := newSymbolManifest(opts.DSYMs.Binaries, opts.DSYMs.dSYMs())
+	report.DSYMs = manifest.DSYMs
+	if err := s.exportSymbolManifest(opts, manifest); err != nil {
+		return err
+	}
+
+	if err := s.exportReport(opts, report); err != nil {
+		return err
+	}
+
+	if opts.DSYMUpload.isEnabled() {
+		if err := s.uploadDSYMs(opts, dsymZipPaths); err != nil {
+			return err
+		}
+	}
 
-		for _, ipa := range ipas {
-			base := filepath.Base(ipa)
-			deployPth := filepath.Join(opts.DeployDir, base)
+	if err := s.verifySymbolManifest(manifest, opts.FailOnDSYMMismatch); err != nil {
+		return err
+	}
+
+	return s.verifyThinningSizeBudget(thinningReports, opts.Thinning.SizeBudget)
+}
+
+// exportIDEDistributionLogs zips the xcdistributionlogs of every failed export,
+// the first one is exported in the unsuffixed Environment Variable like the ipa path.
+func (s Step) exportIDEDistributionLogs(opts ExportOpts, failedExports []MethodExport) {
+	for i, export := range failedExports {
+		zipPath := filepath.Join(opts.DeployDir, opts.FileNames.methodFileName("xcodebuild.xcdistributionlogs", ".xcdistributionlogs", ".zip", export.DistributionMethod))
+		methodEnvKey := s.outputKey(distributionMethodEnvKey(bitriseIDEDistributionLogsPthEnvKey, export.DistributionMethod))
+		if err := s.zipAndExportOutput([]string{export.IDEDistrubutionLogDir}, zipPath, methodEnvKey); err != nil {
+			s.logger.Warnf("Failed to export %s, error: %s", methodEnvKey, err)
+			continue
+		}
 
-			if err := v1command.CopyFile(ipa, deployPth); err != nil {
-				return fmt.Errorf("failed to copy (%s) -> (%s), error: %s", ipas[0], ipa, err)
+		if i == 0 {
+			envKey := s.outputKey(bitriseIDEDistributionLogsPthEnvKey)
+			if err := s.exportOutputFile(zipPath, zipPath, envKey); err != nil {
+				s.logger.Warnf("Failed to export %s, error: %s", envKey, err)
 			}
-			exportedIPAPath = ipa
 		}
 	}
+}
+
+func (s Step) exportSymbolManifest(opts ExportOpts, manifest SymbolManifest) error {
+	manifestContent, err := manifest.String()
+	if err != nil {
+		return err
+	}
 
-	if err := output.ExportOutputFile(exportedIPAPath, exportedIPAPath, bitriseIPAPthEnvKey); err != nil {
-		return fmt.Errorf("failed to export %s, error: %s", bitriseIPAPthEnvKey, err)
+	manifestPath := filepath.Join(opts.DeployDir, "symbol_manifest.json")
+	envKey := s.outputKey(bitriseSymbolManifestPthEnvKey)
+	if err := s.exportOutputFileContent(manifestContent, manifestPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 	}
 
-	s.logger.Donef("The ipa path is now available in the Environment Variable: %s (value: %s)", bitriseIPAPthEnvKey, exportedIPAPath)
+	s.logger.Donef("The symbol manifest path is now available in the Environment Variable: %s (value: %s)", envKey, manifestPath)
 
-	if len(opts.AppDSYMs) == 0 {
-		s.logger.Warnf("No dSYM was found in the archive")
+	return nil
+}
+
+// verifySymbolManifest warns about the binaries without a matching dSYM, and fails if failOnMismatch is set.
+func (s Step) verifySymbolManifest(manifest SymbolManifest, failOnMismatch bool) error {
+	unsymbolicated := manifest.unsymbolicated()
+	if len(unsymbolicated) == 0 {
 		return nil
 	}
 
-	dsymZipPath := filepath.Join(opts.DeployDir, opts.ArchiveName+".dSYM.zip")
-	if err := output.ZipAndExportOutput(opts.AppDSYMs, dsymZipPath, bitriseDSYMPthEnvKey); err != nil {
-		return fmt.Errorf("failed to export %s, error: %s", bitriseDSYMPthEnvKey, err)
+	s.logger.Warnf("No dSYM matches the UUID of %d binaries, their crash reports can not be symbolicated:", len(unsymbolicated))
+	for _, binary := range unsymbolicated {
+		s.logger.Warnf("- %s (%s, %s)", filepath.Base(binary.Path), binary.Arch, binary.UUID)
+	}
+
+	if failOnMismatch {
+		return fmt.Errorf("no dSYM matches the UUID of %d binaries", len(unsymbolicated))
+	}
+
+	return nil
+}
+
+func (s Step) exportReport(opts ExportOpts, report ExportReport) error {
+	reportContent, err := report.String()
+	if err != nil {
+		return err
+	}
+
+	reportPath := filepath.Join(opts.DeployDir, "export_report.json")
+	envKey := s.outputKey(bitriseExportReportPthEnvKey)
+	if err := s.exportOutputFileContent(reportContent, reportPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The export report path is now available in the Environment Variable: %s (value: %s)", envKey, reportPath)
+
+	return nil
+}
+
+func (s Step) exportPlan(opts ExportOpts) error {
+	plan := ExportPlan{
+		ArchivePath: opts.ArchivePath,
+	}
+	for _, export := range opts.Exports {
+		plan.Exports = append(plan.Exports, export.Plan)
+	}
+
+	planContent, err := plan.String()
+	if err != nil {
+		return err
+	}
+
+	s.logger.Infof("Export plan:")
+	s.logger.Printf("%s", planContent)
+	s.logger.Println()
+
+	planPath := filepath.Join(opts.DeployDir, "export_plan.json")
+	envKey := s.outputKey(bitriseExportPlanPthEnvKey)
+	if err := s.exportOutputFileContent(planContent, planPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
 	}
 
-	s.logger.Donef("The dSYM zip path is now available in the Environment Variable: %s (value: %s)", bitriseDSYMPthEnvKey, dsymZipPath)
+	s.logger.Donef("The export plan path is now available in the Environment Variable: %s (value: %s)", envKey, planPath)
 
 	return nil
 }
 
+// exportIPA deploys every IPA of the export, and exports the primary one as the distribution method's IPA path.
+// xcodebuild exports multiple IPAs for example if the archive contains an App Clip,
+// the primary IPA is selected by selectPrimaryIPA.
+func (s Step) exportIPA(export MethodExport, deployDir string, fileNames outputFileNames, productBundleID string) (string, []ExportedIPA, error) {
+	ipas, err := exportedIPAPaths(export)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if len(ipas) == 0 {
+		return "", nil, fmt.Errorf("no ipa found in the export dir: %s", export.ExportDir)
+	} else if len(ipas) > 1 {
+		s.logger.Printf("%d ipa files exported", len(ipas))
+	}
+
+	var deployedIPAs []ExportedIPA
+	for _, ipa := range ipas {
+		ipaName := strings.TrimSuffix(filepath.Base(ipa), ".ipa")
+		kind := ""
+		if len(ipas) > 1 {
+			// The IPA name keeps the IPAs of the export apart if the file name template is set
+			kind = "." + ipaName
+		}
+		deployPth := filepath.Join(deployDir, fileNames.methodFileName(ipaName, kind, ".ipa", export.DistributionMethod))
+
+		if err := v1command.CopyFile(ipa, deployPth); err != nil {
+			return "", nil, fmt.Errorf("failed to copy (%s) -> (%s), error: %s", ipa, deployPth, err)
+		}
+
+		deployedIPA := ExportedIPA{
+			Path:               deployPth,
+			DistributionMethod: export.DistributionMethod,
+		}
+		if deployedIPA.BundleID, deployedIPA.App, err = readIPAApp(deployPth); err != nil {
+			s.logger.Warnf("Failed to read the application of the ipa, error: %s", err)
+		}
+		deployedIPAs = append(deployedIPAs, deployedIPA)
+	}
+
+	// The primary IPA comes first, it is the first artifact of the export in the report too
+	primary := selectPrimaryIPA(deployedIPAs, productBundleID)
+	deployedIPAs[0], deployedIPAs[primary] = deployedIPAs[primary], deployedIPAs[0]
+	exportedIPAPath := deployedIPAs[0].Path
+	if len(deployedIPAs) > 1 {
+		for _, ipa := range deployedIPAs {
+			s.logger.Printf("- %s: %s (%s)", filepath.Base(ipa.Path), ipa.App, ipa.BundleID)
+		}
+		s.logger.Printf("Primary ipa: %s", filepath.Base(exportedIPAPath))
+	}
+
+	envKey := s.outputKey(distributionMethodEnvKey(bitriseIPAPthEnvKey, export.DistributionMethod))
+	if err := s.exportOutputFile(exportedIPAPath, exportedIPAPath, envKey); err != nil {
+		return "", nil, fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The %s ipa path is now available in the Environment Variable: %s (value: %s)", export.DistributionMethod, envKey, exportedIPAPath)
+
+	return exportedIPAPath, deployedIPAs, nil
+}
+
 func RunStep() error {
 	envRepository := env.NewRepository()
 
@@ -474,31 +1230,62 @@ func RunStep() error {
 		commandFactory: command.NewFactory(envRepository),
 		inputParser:    stepconf.NewInputParser(envRepository),
 		logger:         log.NewLogger(),
+		exclusive:      &sync.Mutex{},
 	}
 
-	config, err := step.ProcessInputs()
-	if err != nil {
+	var inputs Inputs
+	if err := step.inputParser.Parse(&inputs); err != nil {
+		err = fmt.Errorf("issue with input: %s", err)
 		step.logger.Errorf(err.Error())
 		return err
 	}
 
-	out, runErr := step.Run(config)
+	if isMultiArchivePath(inputs.ArchivePath) {
+		return step.exportArchives(inputs)
+	}
+
+	return step.exportXcarchive(inputs)
+}
+
+// exportXcarchive runs the whole export of a single archive.
+func (s Step) exportXcarchive(inputs Inputs) error {
+	config, err := s.ProcessInputs(inputs)
+	if err != nil {
+		s.logger.Errorf(err.Error())
+		return err
+	}
+	defer s.cleanupArchive(config.ArchiveTmpDir)
+
+	out, runErr := s.Run(config)
 
 	exportOpts := ExportOpts{
-		IDEDistrubutionLogDir: out.IDEDistrubutionLogDir,
-		TmpDir:                out.TmpDir,
-		DeployDir:             config.DeployDir,
-		AppDSYMs:              out.AppDSYMs,
-		ArchiveName:           out.ArchiveName,
+		Exports:             out.Exports,
+		DistributionMethods: config.DistributionMethods,
+		DeployDir:           config.DeployDir,
+		ProductToDistribute: config.ProductToDistribute,
+		DSYMs:               out.DSYMs,
+		ArchiveName:         out.ArchiveName,
+		ArchivePath:         config.ArchivePath,
+		IsMacOS:             config.IsMacOS,
+		Archive:             config.Archive,
+		MacosArchive:        config.MacosArchive,
+		XcodebuildVersion:   config.XcodebuildVersion,
+		OTAInstall:          config.OTAInstall,
+		FileNames:           config.FileNames,
+		Thinning:            config.Thinning,
+		FailOnDSYMMismatch:  config.FailOnDSYMMismatch,
+		DSYMUpload:          config.DSYMUpload,
+		DryRun:              config.DryRun,
 	}
-	exportErr := step.ExportOutput(exportOpts)
+	// Only the envman calls of the output export are exclusive, zipping, verifying and uploading run concurrently
+	exportErr := s.ExportOutput(exportOpts)
 
 	if runErr != nil {
-		step.logger.Errorf(runErr.Error())
+		s.logger.Errorf(runErr.Error())
 		return runErr
 	}
 	if exportErr != nil {
-		step.logger.Errorf(exportErr.Error())
+		s.logger.Errorf(exportErr.Error())
 		return exportErr
 	}
 
@@ -1,37 +1,207 @@
 package main
 
 import (
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
-	"github.com/teamlapse/go-xcode/utility"
-	"github.com/teamlapse/go-xcode/v2/xcarchive"
 	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/models"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+	"howett.net/plist"
+)
+
+const (
+	testBundleID = "io.bitrise.sample"
+	testTeamID   = "72SA8V3WYL"
 )
 
+type fakeCodesignAssetProvider struct {
+	certificates          []certificateutil.CertificateInfoModel
+	installerCertificates []certificateutil.CertificateInfoModel
+	profiles              []profileutil.ProvisioningProfileInfoModel
+}
+
+func (p fakeCodesignAssetProvider) ListCodesignIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	return p.certificates, nil
+}
+
+func (p fakeCodesignAssetProvider) ListInstallerIdentities() ([]certificateutil.CertificateInfoModel, error) {
+	return p.installerCertificates, nil
+}
+
+func (p fakeCodesignAssetProvider) ListProvisioningProfiles(profileType profileutil.ProfileType) ([]profileutil.ProvisioningProfileInfoModel, error) {
+	var profiles []profileutil.ProvisioningProfileInfoModel
+	for _, profile := range p.profiles {
+		if profile.Type == profileType {
+			profiles = append(profiles, profile)
+		}
+	}
+	return profiles, nil
+}
+
+func testCertificate(serial, commonName string) certificateutil.CertificateInfoModel {
+	return certificateutil.CertificateInfoModel{
+		CommonName: commonName,
+		TeamName:   "Bitrise Bot",
+		TeamID:     testTeamID,
+		Serial:     serial,
+	}
+}
+
+func testProfile(name, uuid string, method exportoptions.Method, certificates ...certificateutil.CertificateInfoModel) profileutil.ProvisioningProfileInfoModel {
+	return profileutil.ProvisioningProfileInfoModel{
+		UUID:                  uuid,
+		Name:                  name,
+		TeamName:              "Bitrise Bot",
+		TeamID:                testTeamID,
+		BundleID:              testBundleID,
+		ExportType:            method,
+		DeveloperCertificates: certificates,
+		Type:                  profileutil.ProfileTypeIos,
+	}
+}
+
+func testArchive(profile profileutil.ProvisioningProfileInfoModel) xcarchive.IosArchive {
+	return xcarchive.IosArchive{
+		IosArchive: v1xcarchive.IosArchive{
+			Path: "sample.xcarchive",
+			Application: v1xcarchive.IosApplication{
+				IosBaseApplication: v1xcarchive.IosBaseApplication{
+					InfoPlist:           plistutil.PlistData{"CFBundleIdentifier": testBundleID, "DTPlatformName": "iphoneos"},
+					Entitlements:        plistutil.PlistData{},
+					ProvisioningProfile: profile,
+				},
+			},
+		},
+	}
+}
+
+func testCodesignAssets() fakeCodesignAssetProvider {
+	return testTeamCodesignAssets(testTeamID)
+}
+
+// testTeamCodesignAssets returns a development and a distribution certificate with their profiles, all of the team.
+func testTeamCodesignAssets(teamID string) fakeCodesignAssetProvider {
+	developmentCertificate := testCertificate("1", "Apple Development: Bitrise Bot (E89JV3W9K4)")
+	developmentCertificate.TeamID = teamID
+	distributionCertificate := testCertificate("2", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	distributionCertificate.TeamID = teamID
+
+	profiles := []profileutil.ProvisioningProfileInfoModel{
+		testProfile("Sample Development", "development-uuid", exportoptions.MethodDevelopment, developmentCertificate),
+		testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, distributionCertificate),
+	}
+	for i := range profiles {
+		profiles[i].TeamID = teamID
+	}
+
+	return fakeCodesignAssetProvider{
+		certificates: []certificateutil.CertificateInfoModel{developmentCertificate, distributionCertificate},
+		profiles:     profiles,
+	}
+}
+
+// testExportOptionsGenerator returns the generator of the team's test code signing assets,
+// and the archive signed with their development profile.
+func testExportOptionsGenerator(teamID string) (exportOptionsGenerator, xcarchive.IosArchive) {
+	codesignAssets := testTeamCodesignAssets(teamID)
+	return newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false), testArchive(codesignAssets.profiles[0])
+}
+
+func testExportOptionsConfig() exportOptionsConfig {
+	return exportOptionsConfig{
+		Product:           ExportProductApp,
+		XcodebuildVersion: models.XcodebuildVersionModel{Version: "Xcode 15.3", MajorVersion: 15},
+		StripSwiftSymbols: true,
+	}
+}
+
+func readGoldenFile(t *testing.T, name string) string {
+	content, err := os.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatalf("failed to read golden file: %s", err)
+	}
+	return string(content)
+}
+
 func TestConfig_generateExportOptions_plist(t *testing.T) {
 	// Given
-	xcodebuildVersion, _ := utility.GetXcodeVersion()
-	archive, _ := xcarchive.NewIosArchive("configs.ArchivePath")
+	generator, archive := testExportOptionsGenerator(testTeamID)
 
 	// When
-	result, _ := generateExportOptionsPlist("app", "development", "my team id", false, false, xcodebuildVersion.MajorVersion, archive, false)
+	result, _, err := generator.generateExportOptionsPlist("development", archive, testExportOptionsConfig())
 
 	// Then
-	if len(result) == 0 {
-		t.Errorf("plist is empty")
-	}
+	assert.NoError(t, err)
+	assert.Equal(t, readGoldenFile(t, "development_export_options.plist"), result)
+}
+
+func TestConfig_generateExportOptions_plist_appStore(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	config := testExportOptionsConfig()
+	config.TeamID = testTeamID
+	config.ManageVersionAndBuildNumber = true
+
+	// When
+	result, _, err := generator.generateExportOptionsPlist("app-store", archive, config)
 
-	assert.NotEqual(t, 0, len(result))
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, readGoldenFile(t, "app_store_export_options.plist"), result)
+}
+
+func TestConfig_generateExportOptions_plist_xcode153Keys(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	config := testExportOptionsConfig()
+	config.TeamID = testTeamID
+	config.TestFlightInternalTesting = true
+	config.StripSwiftSymbols = false
+	config.Destination = destinationUpload
+
+	// When
+	result, codeSigning, err := generator.generateExportOptionsPlist(exportMethodAppStoreConnect, archive, config)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, exportoptions.MethodAppStore, codeSigning.Method)
+
+	var options map[string]interface{}
+	_, err = plist.Unmarshal([]byte(result), &options)
+	assert.NoError(t, err)
+	assert.Equal(t, true, options[testFlightInternalTestingOnlyKey])
+	assert.Equal(t, false, options[stripSwiftSymbolsKey])
+	assert.Equal(t, destinationUpload, options[destinationKey])
+
+	// When
+	config.XcodebuildVersion = models.XcodebuildVersionModel{Version: "Xcode 15.0", MajorVersion: 15}
+	result, _, err = generator.generateExportOptionsPlist(exportMethodAppStoreConnect, archive, config)
+
+	// Then
+	assert.NoError(t, err)
+	assert.NotContains(t, result, stripSwiftSymbolsKey)
+	assert.NotContains(t, result, destinationKey)
+	assert.NotContains(t, result, testFlightInternalTestingOnlyKey)
 }
 
 func TestConfig_generateExportOptions_plist_validField(t *testing.T) {
 	// Given
-	xcodebuildVersion, _ := utility.GetXcodeVersion()
-	archive, _ := xcarchive.NewIosArchive("configs.ArchivePath")
+	generator, archive := testExportOptionsGenerator("my team id")
+	config := testExportOptionsConfig()
+	config.TeamID = "my team id"
+	config.ManageVersionAndBuildNumber = true
 
 	// When
-	result, err := generateExportOptionsPlist("app", "development", "my team id", false, false, xcodebuildVersion.MajorVersion, archive, true)
+	result, _, err := generator.generateExportOptionsPlist("development", archive, config)
 
 	// Then
 	assert.Nil(t, err)
@@ -42,16 +212,161 @@ func TestConfig_generateExportOptions_plist_validField(t *testing.T) {
 
 func TestConfig_generateExportOptions_plist_updateVersionAndBuildSetToFalse(t *testing.T) {
 	// Given
-	xcodebuildVersion, _ := utility.GetXcodeVersion()
-	archive, _ := xcarchive.NewIosArchive("configs.ArchivePath")
+	generator, archive := testExportOptionsGenerator("my team id")
+	config := testExportOptionsConfig()
+	config.TeamID = "my team id"
 
 	// When
-	result, err := generateExportOptionsPlist("app", "app-store", "my team id", false, false, xcodebuildVersion.MajorVersion, archive, false)
+	result, _, err := generator.generateExportOptionsPlist("app-store", archive, config)
 
 	// Then
 	assert.Nil(t, err)
 
-	if xcodebuildVersion.MajorVersion > 12 && strings.Contains(result, "manageAppVersionAndBuildNumber") == false {
+	if strings.Contains(result, "manageAppVersionAndBuildNumber") == false {
 		t.Errorf("plist does not contain manage app version and build number value for method field")
 	}
 }
+
+func TestConfig_generateExportOptions_plist_tvOS(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	for i := range codesignAssets.profiles {
+		codesignAssets.profiles[i].Type = profileutil.ProfileTypeTvOs
+	}
+	archive := testArchive(codesignAssets.profiles[0])
+	archive.Application.InfoPlist["DTPlatformName"] = "appletvos"
+	generator := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false)
+
+	// When
+	_, codeSigning, err := generator.generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "app-store-uuid", codeSigning.Profiles[testBundleID].UUID)
+}
+
+func TestConfig_generateExportOptions_plist_wrongPlatformProfiles(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	archive := testArchive(codesignAssets.profiles[0])
+	archive.Application.InfoPlist["DTPlatformName"] = "appletvos"
+	generator := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false)
+
+	// When
+	_, _, err := generator.generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+
+	// Then
+	assert.EqualError(t, err, "no tvos provisioning profile found for the archive, only profiles for other platforms (ios) are available")
+}
+
+func TestArchiveProfileType(t *testing.T) {
+	tests := []struct {
+		platformName string
+		want         profileutil.ProfileType
+		wantErr      bool
+	}{
+		{platformName: "iphoneos", want: profileutil.ProfileTypeIos},
+		{platformName: "appletvos", want: profileutil.ProfileTypeTvOs},
+		{platformName: "xros", want: profileutil.ProfileTypeIos},
+		{platformName: "watchos", wantErr: true},
+		{platformName: "", want: profileutil.ProfileTypeIos},
+	}
+	for _, tt := range tests {
+		t.Run(tt.platformName, func(t *testing.T) {
+			archive := testArchive(profileutil.ProvisioningProfileInfoModel{})
+			archive.Application.InfoPlist["DTPlatformName"] = tt.platformName
+			if tt.platformName == "" {
+				delete(archive.Application.InfoPlist, "DTPlatformName")
+			}
+
+			got, err := archiveProfileType(archive)
+
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDirectoryCodesignAssetProvider_ListCodesignIdentities(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	cert, key, err := certificateutil.GenerateTestCertificate(1, testTeamID, "Bitrise Bot", "Apple Development: Bitrise Bot", time.Now().AddDate(1, 0, 0))
+	if err != nil {
+		t.Fatalf("failed to generate certificate: %s", err)
+	}
+	p12, err := certificateutil.NewCertificateInfo(*cert, key).EncodeToP12("pass")
+	if err != nil {
+		t.Fatalf("failed to encode certificate: %s", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "development.p12"), p12, 0600); err != nil {
+		t.Fatalf("failed to write certificate: %s", err)
+	}
+
+	provider := NewDirectoryCodesignAssetProvider(dir, []string{"pass"}, "")
+
+	// When
+	certificates, err := provider.ListCodesignIdentities()
+
+	// Then
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(certificates)) {
+		assert.Equal(t, "Apple Development: Bitrise Bot", certificates[0].CommonName)
+		assert.Equal(t, testTeamID, certificates[0].TeamID)
+	}
+
+	profiles, err := provider.ListProvisioningProfiles(profileutil.ProfileTypeIos)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, len(profiles))
+}
+
+func TestParseDistributionMethods(t *testing.T) {
+	// Given
+	list := "ad-hoc| app-store |"
+
+	// When
+	methods, err := parseDistributionMethods(list, false)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"ad-hoc", "app-store"}, methods)
+}
+
+func TestParseDistributionMethods_xcode153(t *testing.T) {
+	// When
+	methods, err := parseDistributionMethods("release-testing|app-store-connect", false)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"release-testing", "app-store-connect"}, methods)
+}
+
+func TestParseDistributionMethods_macOS(t *testing.T) {
+	// Given
+	list := "developer-id|app-store"
+
+	// When
+	methods, err := parseDistributionMethods(list, true)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"developer-id", "app-store"}, methods)
+}
+
+func TestParseDistributionMethods_invalid(t *testing.T) {
+	for _, list := range []string{"", "appstore", "ad-hoc|ad-hoc", "ad-hoc|release-testing", "developer-id"} {
+		_, err := parseDistributionMethods(list, false)
+		assert.Error(t, err, list)
+	}
+
+	_, err := parseDistributionMethods("ad-hoc", true)
+	assert.Error(t, err)
+}
+
+func TestDistributionMethodEnvKey(t *testing.T) {
+	assert.Equal(t, "BITRISE_IPA_PATH_AD_HOC", distributionMethodEnvKey(bitriseIPAPthEnvKey, "ad-hoc"))
+	assert.Equal(t, "BITRISE_IPA_PATH_APP_STORE", distributionMethodEnvKey(bitriseIPAPthEnvKey, "app-store"))
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"howett.net/plist"
+)
+
+// Export options modes
+const (
+	exportOptionsModeReplace = "replace"
+	exportOptionsModeMerge   = "merge"
+)
+
+// exportOptionsChange is a key of the generated export options changed by the provided export options.
+type exportOptionsChange struct {
+	// Key is the dot separated path of the key, for example: provisioningProfiles.com.example.app
+	Key string
+	// Generated is nil if the key is not present in the generated export options.
+	Generated interface{}
+	Provided  interface{}
+}
+
+func (c exportOptionsChange) String() string {
+	if c.Generated == nil {
+		return fmt.Sprintf("+ %s: %v", c.Key, c.Provided)
+	}
+	return fmt.Sprintf("~ %s: %v -> %v", c.Key, c.Generated, c.Provided)
+}
+
+// mergeExportOptions deep merges the provided export options on top of the generated ones.
+// Dictionaries are merged key by key, any other value of the provided export options overrides the generated one.
+func mergeExportOptions(generatedContent, providedContent string) (string, []exportOptionsChange, error) {
+	var generated map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(generatedContent), &generated); err != nil {
+		return "", nil, fmt.Errorf("failed to parse generated export options, error: %s", err)
+	}
+
+	var provided map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(providedContent), &provided); err != nil {
+		return "", nil, fmt.Errorf("failed to parse provided export options, error: %s", err)
+	}
+
+	var changes []exportOptionsChange
+	merged := deepMergeExportOptions("", generated, provided, &changes)
+
+	content, err := plist.MarshalIndent(merged, plist.XMLFormat, "\t")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal merged export options, error: %s", err)
+	}
+
+	return string(content), changes, nil
+}
+
+// validateProvidedMethod returns an error if the merged export options would export every distribution method with the same method.
+func validateProvidedMethod(exportOptionsMode string, distributionMethods []string, providedOptions map[string]interface{}) error {
+	if exportOptionsMode != exportOptionsModeMerge || len(distributionMethods) < 2 {
+		return nil
+	}
+	if _, ok := providedOptions[exportoptions.MethodKey]; !ok {
+		return nil
+	}
+	return fmt.Errorf("the %s key overrides every distribution method (%s) in merge export options mode, remove the key or specify a single distribution method", exportoptions.MethodKey, strings.Join(distributionMethods, ", "))
+}
+
+func deepMergeExportOptions(keyPrefix string, base, overlay map[string]interface{}, changes *[]exportOptionsChange) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for key, value := range base {
+		merged[key] = value
+	}
+
+	var keys []string
+	for key := range overlay {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		value := overlay[key]
+		baseValue, found := base[key]
+
+		baseMap, baseIsMap := baseValue.(map[string]interface{})
+		overlayMap, overlayIsMap := value.(map[string]interface{})
+		if found && baseIsMap && overlayIsMap {
+			merged[key] = deepMergeExportOptions(keyPrefix+key+".", baseMap, overlayMap, changes)
+			continue
+		}
+
+		if found && fmt.Sprintf("%v", baseValue) == fmt.Sprintf("%v", value) {
+			continue
+		}
+
+		*changes = append(*changes, exportOptionsChange{
+			Key:       keyPrefix + key,
+			Generated: baseValue,
+			Provided:  value,
+		})
+		merged[key] = value
+	}
+
+	return merged
+}
+
+// mergeCodeSigning applies the code signing settings of the provided export options on top of the generated ones.
+func mergeCodeSigning(generated, provided ExportCodeSigning) ExportCodeSigning {
+	merged := generated
+	if provided.Method != "" {
+		merged.Method = provided.Method
+	}
+	if provided.TeamID != "" {
+		merged.TeamID = provided.TeamID
+	}
+	if provided.Certificate.CommonName != "" && provided.Certificate.CommonName != generated.Certificate.CommonName {
+		merged.Certificate = provided.Certificate
+	}
+	if provided.SigningStyle != "" {
+		merged.SigningStyle = provided.SigningStyle
+	}
+
+	merged.Profiles = map[string]profileutil.ProvisioningProfileInfoModel{}
+	for bundleID, profile := range generated.Profiles {
+		merged.Profiles[bundleID] = profile
+	}
+	for bundleID, profile := range provided.Profiles {
+		if generatedProfile, ok := generated.Profiles[bundleID]; ok && (generatedProfile.Name == profile.Name || generatedProfile.UUID == profile.Name) {
+			continue
+		}
+		merged.Profiles[bundleID] = profile
+	}
+
+	return merged
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"howett.net/plist"
+)
+
+func TestMergeExportOptions(t *testing.T) {
+	// Given
+	generated := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>method</key>
+		<string>ad-hoc</string>
+		<key>provisioningProfiles</key>
+		<dict>
+			<key>io.bitrise.sample</key>
+			<string>Sample AdHoc</string>
+			<key>io.bitrise.sample.widget</key>
+			<string>Widget AdHoc</string>
+		</dict>
+		<key>teamID</key>
+		<string>72SA8V3WYL</string>
+	</dict>
+</plist>`
+	provided := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>iCloudContainerEnvironment</key>
+		<string>Production</string>
+		<key>provisioningProfiles</key>
+		<dict>
+			<key>io.bitrise.sample.widget</key>
+			<string>Widget AdHoc 2</string>
+		</dict>
+		<key>teamID</key>
+		<string>72SA8V3WYL</string>
+	</dict>
+</plist>`
+
+	// When
+	content, changes, err := mergeExportOptions(generated, provided)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []exportOptionsChange{
+		{Key: "iCloudContainerEnvironment", Provided: "Production"},
+		{Key: "provisioningProfiles.io.bitrise.sample.widget", Generated: "Widget AdHoc", Provided: "Widget AdHoc 2"},
+	}, changes)
+
+	var options map[string]interface{}
+	_, err = plist.Unmarshal([]byte(content), &options)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"method":                     "ad-hoc",
+		"teamID":                     "72SA8V3WYL",
+		"iCloudContainerEnvironment": "Production",
+		"provisioningProfiles": map[string]interface{}{
+			"io.bitrise.sample":        "Sample AdHoc",
+			"io.bitrise.sample.widget": "Widget AdHoc 2",
+		},
+	}, options)
+}
+
+func TestValidateProvidedMethod(t *testing.T) {
+	providedOptions := map[string]interface{}{exportoptions.MethodKey: "ad-hoc"}
+
+	assert.EqualError(t, validateProvidedMethod(exportOptionsModeMerge, []string{"development", "ad-hoc"}, providedOptions),
+		"the method key overrides every distribution method (development, ad-hoc) in merge export options mode, remove the key or specify a single distribution method")
+	assert.NoError(t, validateProvidedMethod(exportOptionsModeMerge, []string{"ad-hoc"}, providedOptions))
+	assert.NoError(t, validateProvidedMethod(exportOptionsModeMerge, []string{"development", "ad-hoc"}, map[string]interface{}{exportoptions.TeamIDKey: "72SA8V3WYL"}))
+	assert.NoError(t, validateProvidedMethod(exportOptionsModeReplace, []string{"development", "ad-hoc"}, providedOptions))
+}
+
+func TestMergeCodeSigning(t *testing.T) {
+	// Given
+	certificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	generated := ExportCodeSigning{
+		Method:      exportoptions.MethodAdHoc,
+		TeamID:      testTeamID,
+		Certificate: certificate,
+		Profiles: map[string]profileutil.ProvisioningProfileInfoModel{
+			"io.bitrise.sample":        testProfile("Sample AdHoc", "sample-uuid", exportoptions.MethodAdHoc, certificate),
+			"io.bitrise.sample.widget": testProfile("Widget AdHoc", "widget-uuid", exportoptions.MethodAdHoc, certificate),
+		},
+	}
+	provided := ExportCodeSigning{
+		Certificate: certificate,
+		Profiles: map[string]profileutil.ProvisioningProfileInfoModel{
+			"io.bitrise.sample":        {Name: "Sample AdHoc"},
+			"io.bitrise.sample.widget": {Name: "Widget AdHoc 2"},
+		},
+	}
+
+	// When
+	merged := mergeCodeSigning(generated, provided)
+
+	// Then
+	assert.Equal(t, exportoptions.MethodAdHoc, merged.Method)
+	assert.Equal(t, certificate, merged.Certificate)
+	assert.Equal(t, "sample-uuid", merged.Profiles["io.bitrise.sample"].UUID)
+	assert.Equal(t, profileutil.ProvisioningProfileInfoModel{Name: "Widget AdHoc 2"}, merged.Profiles["io.bitrise.sample.widget"])
+	assert.Equal(t, "widget-uuid", generated.Profiles["io.bitrise.sample.widget"].UUID)
+}
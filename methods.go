@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/models"
+	"howett.net/plist"
+)
+
+// Distribution methods introduced by Xcode 15.3, replacing the legacy app-store, ad-hoc and development methods.
+const (
+	exportMethodAppStoreConnect = "app-store-connect"
+	exportMethodReleaseTesting  = "release-testing"
+	exportMethodDebugging       = "debugging"
+)
+
+// Export options keys not known by the exportoptions package
+const (
+	destinationKey                   = "destination"
+	stripSwiftSymbolsKey             = "stripSwiftSymbols"
+	testFlightInternalTestingOnlyKey = "testFlightInternalTestingOnly"
+)
+
+// Values of the destination export option
+const (
+	destinationExport = "export"
+	destinationUpload = "upload"
+)
+
+var legacyExportMethods = map[string]exportoptions.Method{
+	exportMethodAppStoreConnect: exportoptions.MethodAppStore,
+	exportMethodReleaseTesting:  exportoptions.MethodAdHoc,
+	exportMethodDebugging:       exportoptions.MethodDevelopment,
+}
+
+// parseExportMethod parses both the Xcode 15.3 and the legacy distribution method names.
+// The legacy method is returned, as the code signing asset filters work with those.
+func parseExportMethod(method string) (exportoptions.Method, error) {
+	if legacyMethod, ok := legacyExportMethods[method]; ok {
+		return legacyMethod, nil
+	}
+	return exportoptions.ParseMethod(method)
+}
+
+// xcodebuildExportMethod returns the name of the distribution method the given Xcode version expects:
+// the Xcode 15.3 name on Xcode 15.3 and later, the legacy name otherwise.
+func xcodebuildExportMethod(method string, xcodebuildVersion models.XcodebuildVersionModel) string {
+	legacyMethod, err := parseExportMethod(method)
+	if err != nil {
+		return method
+	}
+
+	if !isXcodeVersionAtLeast(xcodebuildVersion, 15, 3) {
+		return string(legacyMethod)
+	}
+
+	for name, m := range legacyExportMethods {
+		if m == legacyMethod {
+			return name
+		}
+	}
+	return string(legacyMethod)
+}
+
+// isXcodeVersionAtLeast compares the Xcode version with the given major and minor version.
+func isXcodeVersionAtLeast(xcodebuildVersion models.XcodebuildVersionModel, major, minor int64) bool {
+	if xcodebuildVersion.MajorVersion != major {
+		return xcodebuildVersion.MajorVersion > major
+	}
+
+	// Version is in the form of: Xcode 15.3
+	split := strings.Split(strings.TrimPrefix(xcodebuildVersion.Version, "Xcode "), ".")
+	if len(split) < 2 {
+		return minor == 0
+	}
+	minorVersion, err := strconv.ParseInt(split[1], 10, 64)
+	if err != nil {
+		return minor == 0
+	}
+	return minorVersion >= minor
+}
+
+// convertExportOptionsMethod replaces the method of the export options with the name the given Xcode version expects.
+func convertExportOptionsMethod(content string, xcodebuildVersion models.XcodebuildVersionModel) (string, error) {
+	var options map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(content), &options); err != nil {
+		return "", fmt.Errorf("failed to parse export options, error: %s", err)
+	}
+
+	method, ok := options[exportoptions.MethodKey].(string)
+	if !ok {
+		return content, nil
+	}
+
+	xcodebuildMethod := xcodebuildExportMethod(method, xcodebuildVersion)
+	if xcodebuildMethod == method {
+		return content, nil
+	}
+
+	log.Printf("Replacing the %s method with %s, as expected by %s", method, xcodebuildMethod, xcodebuildVersion.Version)
+	options[exportoptions.MethodKey] = xcodebuildMethod
+
+	return exportOptionsString(options)
+}
+
+func exportOptionsString(options map[string]interface{}) (string, error) {
+	plistBytes, err := plist.MarshalIndent(options, plist.XMLFormat, "\t")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal export options model, error: %s", err)
+	}
+	return string(plistBytes), nil
+}
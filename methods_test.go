@@ -0,0 +1,55 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/models"
+	"howett.net/plist"
+)
+
+func TestXcodebuildExportMethod(t *testing.T) {
+	xcode152 := models.XcodebuildVersionModel{Version: "Xcode 15.2", MajorVersion: 15}
+	xcode153 := models.XcodebuildVersionModel{Version: "Xcode 15.3", MajorVersion: 15}
+	xcode16 := models.XcodebuildVersionModel{Version: "Xcode 16.0", MajorVersion: 16}
+
+	assert.Equal(t, "ad-hoc", xcodebuildExportMethod(exportMethodReleaseTesting, xcode152))
+	assert.Equal(t, "app-store", xcodebuildExportMethod(exportMethodAppStoreConnect, xcode152))
+	assert.Equal(t, exportMethodReleaseTesting, xcodebuildExportMethod("ad-hoc", xcode153))
+	assert.Equal(t, exportMethodDebugging, xcodebuildExportMethod("development", xcode16))
+	assert.Equal(t, "enterprise", xcodebuildExportMethod("enterprise", xcode16))
+	assert.Equal(t, "developer-id", xcodebuildExportMethod("developer-id", xcode16))
+}
+
+func TestParseExportMethod(t *testing.T) {
+	method, err := parseExportMethod(exportMethodDebugging)
+	assert.NoError(t, err)
+	assert.Equal(t, exportoptions.MethodDevelopment, method)
+
+	_, err = parseExportMethod("appstore")
+	assert.Error(t, err)
+}
+
+func TestConvertExportOptionsMethod(t *testing.T) {
+	// Given
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>method</key>
+		<string>app-store-connect</string>
+	</dict>
+</plist>`
+
+	// When
+	converted, err := convertExportOptionsMethod(content, models.XcodebuildVersionModel{Version: "Xcode 14.3", MajorVersion: 14})
+
+	// Then
+	assert.NoError(t, err)
+
+	var options map[string]interface{}
+	_, err = plist.Unmarshal([]byte(converted), &options)
+	assert.NoError(t, err)
+	assert.Equal(t, "app-store", options["method"])
+}
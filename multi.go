@@ -0,0 +1,250 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-steputils/output"
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/pathutil"
+)
+
+// ArchiveSummary is the export result of an archive in the multi-archive summary.
+type ArchiveSummary struct {
+	Index       int    `json:"index"`
+	ArchivePath string `json:"archive_path"`
+	OutputDir   string `json:"output_dir"`
+	Succeeded   bool   `json:"succeeded"`
+	Error       string `json:"error,omitempty"`
+	// Report is the export report of the archive, nil if the export failed before creating it.
+	Report *ExportReport `json:"report,omitempty"`
+}
+
+// isMultiArchivePath returns true if the archive path is a glob pattern,
+// or a directory containing archives instead of being an archive itself.
+func isMultiArchivePath(archivePath string) bool {
+	archivePath = strings.TrimSpace(archivePath)
+	if isRemoteArchivePath(archivePath) {
+		return false
+	}
+	if strings.ContainsAny(archivePath, "*?[") {
+		return true
+	}
+	if strings.HasSuffix(archivePath, ".xcarchive") || strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		return false
+	}
+
+	if exist, err := pathutil.IsDirExists(archivePath); err != nil || !exist {
+		return false
+	}
+	// Every .xcarchive has an Info.plist in its root
+	if exist, err := pathutil.IsPathExists(filepath.Join(archivePath, "Info.plist")); err != nil || exist {
+		return false
+	}
+	return true
+}
+
+// findArchives returns the archives (.xcarchive directories and zipped archives) matching the glob pattern,
+// or the archives in the directory.
+func findArchives(archivePath string) ([]string, error) {
+	pattern := strings.TrimSpace(archivePath)
+	if !strings.ContainsAny(pattern, "*?[") {
+		pattern = filepath.Join(pattern, "*")
+	}
+
+	matches, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid archive path pattern (%s), error: %s", archivePath, err)
+	}
+
+	var archives []string
+	for _, match := range matches {
+		if strings.HasSuffix(match, ".xcarchive") || strings.HasSuffix(match, ".xcarchive.zip") {
+			archives = append(archives, match)
+		}
+	}
+	if len(archives) == 0 {
+		return nil, fmt.Errorf("no archive found at: %s", archivePath)
+	}
+
+	return archives, nil
+}
+
+// archiveOutputDirNames returns a unique output subdirectory name for every archive, named after the archive.
+func archiveOutputDirNames(archivePaths []string) []string {
+	var names []string
+	used := map[string]bool{}
+	for i, archivePath := range archivePaths {
+		name := strings.TrimSuffix(strings.TrimSuffix(filepath.Base(archivePath), ".zip"), ".xcarchive")
+		if used[name] {
+			name = name + "-" + strconv.Itoa(i)
+		}
+		used[name] = true
+		names = append(names, name)
+	}
+	return names
+}
+
+// exportArchives runs the whole export of every archive matching the archive path, each into its own output subdirectory.
+// A failing archive does not stop the export of the others, the results are exported as indexed outputs and in a summary.
+func (s Step) exportArchives(inputs Inputs) error {
+	archivePaths, err := findArchives(inputs.ArchivePath)
+	if err == nil && strings.TrimSpace(inputs.ArchiveChecksum) != "" {
+		err = fmt.Errorf("archive checksum is not supported for multiple archives")
+	}
+	if err != nil {
+		s.logger.Errorf(err.Error())
+		return err
+	}
+
+	s.logger.Infof("Exporting %d archives:", len(archivePaths))
+	for _, archivePath := range archivePaths {
+		s.logger.Printf("- %s", archivePath)
+	}
+
+	concurrent := inputs.ExportConcurrency > 1
+	if concurrent {
+		s.logger.EnableDebugLog(inputs.VerboseLog)
+		s.logger.Printf("Exporting %d archives at a time, the logs of an archive are printed when its export finishes", inputs.ExportConcurrency)
+	}
+
+	dirNames := archiveOutputDirNames(archivePaths)
+	summaries := make([]ArchiveSummary, len(archivePaths))
+	runJobs(inputs.ExportConcurrency, len(archivePaths), func(i int) error {
+		job, logger := s.newArchiveJob(i, concurrent)
+		if logger != nil {
+			defer s.flushJob(logger)
+		}
+
+		archiveInputs := inputs
+		archiveInputs.ArchivePath = archivePaths[i]
+		archiveInputs.DeployDir = filepath.Join(inputs.DeployDir, dirNames[i])
+		if concurrent {
+			// The archives are exported concurrently, their distribution methods one after the other
+			archiveInputs.ExportConcurrency = 1
+		}
+
+		job.logger.Println()
+		job.logger.Infof("Exporting archive %d/%d: %s", i+1, len(archivePaths), archivePaths[i])
+
+		var exportErr error
+		if err := os.MkdirAll(archiveInputs.DeployDir, 0755); err != nil {
+			exportErr = fmt.Errorf("failed to create output dir, error: %s", err)
+			job.logger.Errorf(exportErr.Error())
+		} else {
+			exportErr = job.exportXcarchive(archiveInputs)
+		}
+
+		summaries[i] = newArchiveSummary(i, archiveInputs.ArchivePath, archiveInputs.DeployDir, exportErr)
+		return exportErr
+	})
+
+	s.logger.Println()
+	for _, summary := range summaries {
+		if err := s.exportArchiveOutputs(summary); err != nil {
+			s.logger.Warnf("Failed to export the outputs of archive %d, error: %s", summary.Index, err)
+		}
+	}
+
+	if err := s.exportArchivesSummary(inputs.DeployDir, summaries); err != nil {
+		s.logger.Errorf(err.Error())
+		return err
+	}
+
+	var failed []string
+	for _, summary := range summaries {
+		if !summary.Succeeded {
+			failed = append(failed, filepath.Base(summary.ArchivePath))
+		}
+	}
+	if len(failed) > 0 {
+		err := fmt.Errorf("failed to export %d of %d archives: %s", len(failed), len(summaries), strings.Join(failed, ", "))
+		s.logger.Errorf(err.Error())
+		return err
+	}
+
+	return nil
+}
+
+func newArchiveSummary(index int, archivePath, outputDir string, exportErr error) ArchiveSummary {
+	summary := ArchiveSummary{
+		Index:       index,
+		ArchivePath: archivePath,
+		OutputDir:   outputDir,
+		Succeeded:   exportErr == nil,
+	}
+	if exportErr != nil {
+		summary.Error = exportErr.Error()
+	}
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "export_report.json"))
+	if err != nil {
+		return summary
+	}
+	var report ExportReport
+	if err := json.Unmarshal(content, &report); err == nil {
+		summary.Report = &report
+	}
+
+	return summary
+}
+
+// newArchiveJob returns the Step exporting the archive of the index, and its logger if the archives are exported concurrently.
+// The outputs of the archive are exported with the archive index suffix only (for example: BITRISE_IPA_PATH_0),
+// the not indexed outputs would be overwritten by every archive.
+func (s Step) newArchiveJob(index int, concurrent bool) (Step, *jobLogger) {
+	job := s
+	var logger *jobLogger
+	if concurrent {
+		job, logger = s.newJob()
+	}
+	job.outputSuffix = fmt.Sprintf("_%d", index)
+	return job, logger
+}
+
+// outputKey returns the env key of an output, suffixed with the archive index in multi-archive mode.
+func (s Step) outputKey(key string) string {
+	return key + s.outputSuffix
+}
+
+// exportArchiveOutputs exports the output dir of an archive with the archive index suffix, for example: BITRISE_EXPORT_DIR_0.
+// The other outputs are exported by the export of the archive.
+func (s Step) exportArchiveOutputs(summary ArchiveSummary) error {
+	envKey := fmt.Sprintf("%s_%d", bitriseExportDirEnvKey, summary.Index)
+	if err := tools.ExportEnvironmentWithEnvman(envKey, summary.OutputDir); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+	s.logger.Printf("The archive output is now available in the Environment Variable: %s (value: %s)", envKey, summary.OutputDir)
+
+	return nil
+}
+
+func (s Step) exportArchivesSummary(deployDir string, summaries []ArchiveSummary) error {
+	s.logger.Println()
+	s.logger.Infof("Archive export summary:")
+
+	for _, summary := range summaries {
+		if summary.Succeeded {
+			s.logger.Donef("- %d: %s", summary.Index, summary.ArchivePath)
+		} else {
+			s.logger.Errorf("- %d: %s: %s", summary.Index, summary.ArchivePath, summary.Error)
+		}
+	}
+
+	content, err := json.MarshalIndent(summaries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal export summary, error: %s", err)
+	}
+	summaryPath := filepath.Join(deployDir, "export_summary.json")
+	if err := output.ExportOutputFileContent(string(content), summaryPath, bitriseExportSummaryPthEnvKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", bitriseExportSummaryPthEnvKey, err)
+	}
+
+	s.logger.Donef("The export summary path is now available in the Environment Variable: %s (value: %s)", bitriseExportSummaryPthEnvKey, summaryPath)
+
+	return nil
+}
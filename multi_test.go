@@ -0,0 +1,119 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsMultiArchivePath(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	archivePath := filepath.Join(dir, "Sample.xcarchive")
+	assert.NoError(t, os.MkdirAll(archivePath, 0755))
+	archiveWithoutExtension := filepath.Join(dir, "archive")
+	assert.NoError(t, os.MkdirAll(archiveWithoutExtension, 0755))
+	assert.NoError(t, os.WriteFile(filepath.Join(archiveWithoutExtension, "Info.plist"), nil, 0644))
+
+	// Then
+	assert.True(t, isMultiArchivePath(dir))
+	assert.True(t, isMultiArchivePath(filepath.Join(dir, "*.xcarchive")))
+	assert.False(t, isMultiArchivePath(archivePath))
+	assert.False(t, isMultiArchivePath(archiveWithoutExtension))
+	assert.False(t, isMultiArchivePath(filepath.Join(dir, "Sample.xcarchive.zip")))
+	assert.False(t, isMultiArchivePath("https://example.com/builds/*/Sample.xcarchive.zip"))
+}
+
+func TestFindArchives(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	for _, name := range []string{"App.xcarchive", "Widget.xcarchive", "logs"} {
+		assert.NoError(t, os.MkdirAll(filepath.Join(dir, name), 0755))
+	}
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "Clip.xcarchive.zip"), nil, 0644))
+
+	// When
+	archives, err := findArchives(dir)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		filepath.Join(dir, "App.xcarchive"),
+		filepath.Join(dir, "Clip.xcarchive.zip"),
+		filepath.Join(dir, "Widget.xcarchive"),
+	}, archives)
+
+	archives, err = findArchives(filepath.Join(dir, "W*.xcarchive"))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(dir, "Widget.xcarchive")}, archives)
+
+	_, err = findArchives(filepath.Join(dir, "logs"))
+	assert.Error(t, err)
+}
+
+func TestArchiveOutputDirNames(t *testing.T) {
+	names := archiveOutputDirNames([]string{"a/App.xcarchive", "b/App.xcarchive.zip", "Clip.xcarchive"})
+	assert.Equal(t, []string{"App", "App-1", "Clip"}, names)
+}
+
+func TestNewArchiveSummary(t *testing.T) {
+	// Given
+	outputDir := t.TempDir()
+	report := `{"archive_path": "App.xcarchive", "exports": [{"distribution_method": "app-store", "artifacts": [{"path": "/deploy/App/App.ipa"}]}]}`
+	assert.NoError(t, os.WriteFile(filepath.Join(outputDir, "export_report.json"), []byte(report), 0644))
+
+	// When
+	summary := newArchiveSummary(1, "App.xcarchive", outputDir, errors.New("code signing verification failed"))
+
+	// Then
+	assert.False(t, summary.Succeeded)
+	assert.Equal(t, "code signing verification failed", summary.Error)
+	assert.Equal(t, "/deploy/App/App.ipa", summary.Report.Exports[0].Artifacts[0].Path)
+
+	summary = newArchiveSummary(2, "Clip.xcarchive", t.TempDir(), nil)
+	assert.True(t, summary.Succeeded)
+	assert.Nil(t, summary.Report)
+}
+
+// fakeEnvman puts an envman into the PATH, which records the exported outputs, and returns the path of the record.
+func fakeEnvman(t *testing.T) string {
+	dir := t.TempDir()
+	recordPath := filepath.Join(dir, "envs")
+	script := "#!/bin/sh\necho \"$3=$(cat)\" >> " + recordPath + "\n"
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "envman"), []byte(script), 0755))
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	return recordPath
+}
+
+func TestExportArchives_indexedOutputs(t *testing.T) {
+	// Given
+	recordPath := fakeEnvman(t)
+	step := Step{logger: &recordingLogger{}}
+	deployDirs := []string{t.TempDir(), t.TempDir()}
+
+	// When
+	for i, deployDir := range deployDirs {
+		job, _ := step.newArchiveJob(i, false)
+		assert.NoError(t, job.exportReport(ExportOpts{DeployDir: deployDir}, ExportReport{}))
+		assert.NoError(t, job.exportArchiveOutputs(ArchiveSummary{Index: i, OutputDir: deployDir}))
+	}
+
+	// Then
+	content, err := os.ReadFile(recordPath)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		"BITRISE_EXPORT_REPORT_PATH_0=" + filepath.Join(deployDirs[0], "export_report.json"),
+		"BITRISE_EXPORT_DIR_0=" + deployDirs[0],
+		"BITRISE_EXPORT_REPORT_PATH_1=" + filepath.Join(deployDirs[1], "export_report.json"),
+		"BITRISE_EXPORT_DIR_1=" + deployDirs[1],
+	}, strings.Split(strings.TrimSpace(string(content)), "\n"))
+
+	job, _ := step.newArchiveJob(1, true)
+	assert.Equal(t, "BITRISE_IPA_PATH_AD_HOC_1", job.outputKey(distributionMethodEnvKey(bitriseIPAPthEnvKey, "ad-hoc")))
+	assert.Equal(t, "BITRISE_DSYM_PATH_1", job.outputKey(bitriseDSYMPthEnvKey))
+	assert.Equal(t, "BITRISE_IPA_PATH", step.outputKey(bitriseIPAPthEnvKey))
+}
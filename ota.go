@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"howett.net/plist"
+)
+
+// otaInstallConfig configures the over-the-air install manifest and page of ad-hoc and enterprise exports.
+type otaInstallConfig struct {
+	// BaseURL is the URL the IPA, the manifest and the install page are downloaded from.
+	BaseURL          string
+	DisplayImageURL  string
+	FullSizeImageURL string
+}
+
+func (c otaInstallConfig) isEnabled() bool {
+	return c.BaseURL != ""
+}
+
+func (c otaInstallConfig) fileURL(pth string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + url.PathEscape(filepath.Base(pth))
+}
+
+// parseOTAInstallConfig validates the OTA install URLs.
+func parseOTAInstallConfig(baseURL, displayImageURL, fullSizeImageURL string) (otaInstallConfig, error) {
+	config := otaInstallConfig{
+		BaseURL:          strings.TrimSpace(baseURL),
+		DisplayImageURL:  strings.TrimSpace(displayImageURL),
+		FullSizeImageURL: strings.TrimSpace(fullSizeImageURL),
+	}
+
+	if !config.isEnabled() {
+		if config.DisplayImageURL != "" || config.FullSizeImageURL != "" {
+			return otaInstallConfig{}, fmt.Errorf("icon URLs are specified without a base download URL")
+		}
+		return config, nil
+	}
+
+	for _, u := range []string{config.BaseURL, config.DisplayImageURL, config.FullSizeImageURL} {
+		if u == "" {
+			continue
+		}
+
+		parsed, err := url.Parse(u)
+		if err != nil {
+			return otaInstallConfig{}, fmt.Errorf("invalid URL (%s), error: %s", u, err)
+		}
+		// iOS installs apps over-the-air only from HTTPS URLs
+		if parsed.Scheme != "https" || parsed.Host == "" {
+			return otaInstallConfig{}, fmt.Errorf("invalid URL (%s), an absolute https URL is required", u)
+		}
+	}
+
+	return config, nil
+}
+
+// isOTAInstallMethod returns true if the IPAs exported with the distribution method can be installed over-the-air.
+func isOTAInstallMethod(distributionMethod string) bool {
+	method, err := parseExportMethod(distributionMethod)
+	if err != nil {
+		return false
+	}
+	return method == exportoptions.MethodAdHoc || method == exportoptions.MethodEnterprise
+}
+
+// otaManifest returns the content of the manifest.plist the itms-services:// link refers to.
+func otaManifest(manifest exportoptions.Manifest, appInfoPlist plistutil.PlistData) (string, error) {
+	assets := []map[string]string{
+		{"kind": "software-package", "url": manifest.AppURL},
+	}
+	if manifest.DisplayImageURL != "" {
+		assets = append(assets, map[string]string{"kind": "display-image", "url": manifest.DisplayImageURL})
+	}
+	if manifest.FullSizeImageURL != "" {
+		assets = append(assets, map[string]string{"kind": "full-size-image", "url": manifest.FullSizeImageURL})
+	}
+
+	content := map[string]interface{}{
+		"items": []map[string]interface{}{
+			{
+				"assets": assets,
+				"metadata": map[string]string{
+					"bundle-identifier": stringValue(appInfoPlist, "CFBundleIdentifier"),
+					"bundle-version":    stringValue(appInfoPlist, "CFBundleShortVersionString"),
+					"kind":              "software",
+					"title":             appTitle(appInfoPlist),
+				},
+			},
+		},
+	}
+
+	data, err := plist.MarshalIndent(content, plist.XMLFormat, "\t")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal OTA manifest, error: %s", err)
+	}
+	return string(data), nil
+}
+
+func appTitle(appInfoPlist plistutil.PlistData) string {
+	for _, key := range []string{"CFBundleDisplayName", "CFBundleName"} {
+		if title := stringValue(appInfoPlist, key); title != "" {
+			return title
+		}
+	}
+	return stringValue(appInfoPlist, "CFBundleIdentifier")
+}
+
+var otaInstallPageTemplate = template.Must(template.New("install").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>Install {{.Title}}</title>
+<style>
+body { font-family: -apple-system, Helvetica, Arial, sans-serif; text-align: center; margin: 48px 16px; color: #222; }
+img { width: 120px; height: 120px; border-radius: 24px; }
+a.install { display: inline-block; margin-top: 24px; padding: 14px 32px; border-radius: 10px; background: #0a84ff; color: #fff; text-decoration: none; font-size: 18px; }
+p.note { color: #777; font-size: 14px; }
+</style>
+</head>
+<body>
+{{if .DisplayImageURL}}<img src="{{.DisplayImageURL}}" alt="">
+{{end}}<h1>{{.Title}}</h1>
+<p>Version {{.Version}} ({{.BuildNumber}})</p>
+<a class="install" href="{{.InstallURL}}">Install</a>
+<p class="note">Open this page on the iOS device the app should be installed on.</p>
+</body>
+</html>
+`))
+
+// otaInstallPage returns a self-contained HTML page with the itms-services:// install link of the manifest.
+func otaInstallPage(manifestURL, displayImageURL string, appInfoPlist plistutil.PlistData) (string, error) {
+	data := struct {
+		Title           string
+		Version         string
+		BuildNumber     string
+		DisplayImageURL string
+		InstallURL      template.URL
+	}{
+		Title:           appTitle(appInfoPlist),
+		Version:         stringValue(appInfoPlist, "CFBundleShortVersionString"),
+		BuildNumber:     stringValue(appInfoPlist, "CFBundleVersion"),
+		DisplayImageURL: displayImageURL,
+		// html/template rejects the itms-services scheme, the URL is built from validated parts.
+		InstallURL: template.URL("itms-services://?action=download-manifest&url=" + url.QueryEscape(manifestURL)),
+	}
+
+	var buf bytes.Buffer
+	if err := otaInstallPageTemplate.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render OTA install page, error: %s", err)
+	}
+	return buf.String(), nil
+}
+
+// exportOTAInstall writes the OTA manifest and install page of the IPA next to it,
+// and returns the paths of the written files.
+func (s Step) exportOTAInstall(config otaInstallConfig, export MethodExport, ipaPath string, appInfoPlist plistutil.PlistData, deployDir string, fileNames outputFileNames) ([]string, error) {
+	manifestPath := filepath.Join(deployDir, fileNames.methodFileName("manifest", ".manifest", ".plist", export.DistributionMethod))
+	installPagePath := filepath.Join(deployDir, fileNames.methodFileName("install", ".install", ".html", export.DistributionMethod))
+
+	manifestContent, err := otaManifest(exportoptions.Manifest{
+		AppURL:           config.fileURL(ipaPath),
+		DisplayImageURL:  config.DisplayImageURL,
+		FullSizeImageURL: config.FullSizeImageURL,
+	}, appInfoPlist)
+	if err != nil {
+		return nil, err
+	}
+
+	installPageContent, err := otaInstallPage(config.fileURL(manifestPath), config.DisplayImageURL, appInfoPlist)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, item := range []struct {
+		content string
+		pth     string
+		envKey  string
+	}{
+		{manifestContent, manifestPath, bitriseOTAManifestPthEnvKey},
+		{installPageContent, installPagePath, bitriseOTAInstallPagePthEnvKey},
+	} {
+		envKey := s.outputKey(distributionMethodEnvKey(item.envKey, export.DistributionMethod))
+		if err := s.exportOutputFileContent(item.content, item.pth, envKey); err != nil {
+			return nil, fmt.Errorf("failed to export %s, error: %s", envKey, err)
+		}
+
+		s.logger.Donef("The %s OTA file path is now available in the Environment Variable: %s (value: %s)", export.DistributionMethod, envKey, item.pth)
+	}
+
+	return []string{manifestPath, installPagePath}, nil
+}
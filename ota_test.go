@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"howett.net/plist"
+)
+
+func testAppInfoPlist() plistutil.PlistData {
+	return plistutil.PlistData{
+		"CFBundleIdentifier":         testBundleID,
+		"CFBundleShortVersionString": "1.2.0",
+		"CFBundleVersion":            "42",
+		"CFBundleName":               "Sample & Co",
+	}
+}
+
+func TestParseOTAInstallConfig(t *testing.T) {
+	config, err := parseOTAInstallConfig(" https://example.com/builds/42/ ", "https://example.com/icon.png", "")
+	assert.NoError(t, err)
+	assert.Equal(t, "https://example.com/builds/42/Sample%20App.ipa", config.fileURL("/deploy/Sample App.ipa"))
+
+	for _, urls := range [][]string{
+		{"http://example.com/builds", ""},
+		{"builds/42", ""},
+		{"", "https://example.com/icon.png"},
+	} {
+		_, err := parseOTAInstallConfig(urls[0], urls[1], "")
+		assert.Error(t, err, urls)
+	}
+}
+
+func TestOTAManifest(t *testing.T) {
+	// When
+	content, err := otaManifest(exportoptions.Manifest{
+		AppURL:          "https://example.com/builds/42/Sample.ipa",
+		DisplayImageURL: "https://example.com/icon.png",
+	}, testAppInfoPlist())
+
+	// Then
+	assert.NoError(t, err)
+
+	var manifest map[string]interface{}
+	_, err = plist.Unmarshal([]byte(content), &manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{
+				"assets": []interface{}{
+					map[string]interface{}{"kind": "software-package", "url": "https://example.com/builds/42/Sample.ipa"},
+					map[string]interface{}{"kind": "display-image", "url": "https://example.com/icon.png"},
+				},
+				"metadata": map[string]interface{}{
+					"bundle-identifier": testBundleID,
+					"bundle-version":    "1.2.0",
+					"kind":              "software",
+					"title":             "Sample & Co",
+				},
+			},
+		},
+	}, manifest)
+}
+
+func TestOTAInstallPage(t *testing.T) {
+	// When
+	page, err := otaInstallPage("https://example.com/builds/42/manifest.plist", "", testAppInfoPlist())
+
+	// Then
+	assert.NoError(t, err)
+	assert.Contains(t, page, `href="itms-services://?action=download-manifest&amp;url=https%3A%2F%2Fexample.com%2Fbuilds%2F42%2Fmanifest.plist"`)
+	assert.Contains(t, page, "<h1>Sample &amp; Co</h1>")
+	assert.Contains(t, page, "Version 1.2.0 (42)")
+	assert.NotContains(t, page, "<img")
+}
+
+func TestIsOTAInstallMethod(t *testing.T) {
+	assert.True(t, isOTAInstallMethod("ad-hoc"))
+	assert.True(t, isOTAInstallMethod(exportMethodReleaseTesting))
+	assert.True(t, isOTAInstallMethod("enterprise"))
+	assert.False(t, isOTAInstallMethod("app-store"))
+}
@@ -0,0 +1,304 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/bitrise-io/go-steputils/tools"
+	"github.com/bitrise-io/go-utils/command"
+	"github.com/bitrise-io/go-utils/fileutil"
+	v1log "github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/v2/log"
+	"github.com/bitrise-io/go-utils/ziputil"
+)
+
+// jobLogger records the log messages of an export job, and prints them at once when the job finishes,
+// so the logs of the concurrent jobs do not interleave.
+// It is also an io.Writer, to record the messages of the global (v1) logger during the exclusive stages of the job.
+type jobLogger struct {
+	mu      sync.Mutex
+	entries []func(logger log.Logger)
+}
+
+func (l *jobLogger) record(entry func(logger log.Logger)) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, entry)
+}
+
+// Infof ...
+func (l *jobLogger) Infof(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Infof(format, v...) })
+}
+
+// Warnf ...
+func (l *jobLogger) Warnf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Warnf(format, v...) })
+}
+
+// Printf ...
+func (l *jobLogger) Printf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Printf(format, v...) })
+}
+
+// Donef ...
+func (l *jobLogger) Donef(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Donef(format, v...) })
+}
+
+// Debugf ...
+func (l *jobLogger) Debugf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Debugf(format, v...) })
+}
+
+// Errorf ...
+func (l *jobLogger) Errorf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.Errorf(format, v...) })
+}
+
+// TInfof ...
+func (l *jobLogger) TInfof(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TInfof(format, v...) })
+}
+
+// TWarnf ...
+func (l *jobLogger) TWarnf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TWarnf(format, v...) })
+}
+
+// TPrintf ...
+func (l *jobLogger) TPrintf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TPrintf(format, v...) })
+}
+
+// TDonef ...
+func (l *jobLogger) TDonef(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TDonef(format, v...) })
+}
+
+// TDebugf ...
+func (l *jobLogger) TDebugf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TDebugf(format, v...) })
+}
+
+// TErrorf ...
+func (l *jobLogger) TErrorf(format string, v ...interface{}) {
+	l.record(func(logger log.Logger) { logger.TErrorf(format, v...) })
+}
+
+// Println ...
+func (l *jobLogger) Println() {
+	l.record(func(logger log.Logger) { logger.Println() })
+}
+
+// EnableDebugLog is applied on the step logger when the job logs are printed.
+func (l *jobLogger) EnableDebugLog(enable bool) {
+	l.record(func(logger log.Logger) { logger.EnableDebugLog(enable) })
+}
+
+// Write records the raw output of the global logger.
+func (l *jobLogger) Write(p []byte) (int, error) {
+	content := string(p)
+	l.record(func(log.Logger) {
+		if _, err := os.Stdout.WriteString(content); err != nil {
+			v1log.Warnf("Failed to print log, error: %s", err)
+		}
+	})
+	return len(p), nil
+}
+
+func (l *jobLogger) flush(logger log.Logger) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, entry := range l.entries {
+		entry(logger)
+	}
+	l.entries = nil
+}
+
+// newJob returns a copy of the step for a concurrent export job, which logs into its own buffer.
+func (s Step) newJob() (Step, *jobLogger) {
+	logger := &jobLogger{}
+	job := s
+	job.logger = logger
+	return job, logger
+}
+
+// flushJob prints the logs of a finished export job.
+func (s Step) flushJob(logger *jobLogger) {
+	if s.exclusive != nil {
+		s.exclusive.Lock()
+		defer s.exclusive.Unlock()
+	}
+	logger.flush(s.logger)
+}
+
+// acquireExclusive waits until no other export job runs an exclusive stage, and returns the function releasing it.
+// The keychain, the global logger and the envman env store can not be used by concurrent export jobs.
+func (s Step) acquireExclusive() (release func()) {
+	if s.exclusive == nil {
+		return func() {}
+	}
+
+	s.exclusive.Lock()
+	if logger, ok := s.logger.(*jobLogger); ok {
+		v1log.SetOutWriter(logger)
+	}
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			if _, ok := s.logger.(*jobLogger); ok {
+				v1log.SetOutWriter(os.Stdout)
+			}
+			s.exclusive.Unlock()
+		})
+	}
+}
+
+// exportEnv exports the Environment Variable, in an exclusive stage as the envman env store is shared by the export jobs.
+func (s Step) exportEnv(envKey, value string) error {
+	release := s.acquireExclusive()
+	defer release()
+
+	return tools.ExportEnvironmentWithEnvman(envKey, value)
+}
+
+// exportOutputFile copies the file to the destination and exports its path, like output.ExportOutputFile,
+// only the envman call runs in an exclusive stage.
+func (s Step) exportOutputFile(sourcePth, destinationPth, envKey string) error {
+	absSourcePth, err := pathutil.AbsPath(sourcePth)
+	if err != nil {
+		return err
+	}
+
+	absDestinationPth, err := pathutil.AbsPath(destinationPth)
+	if err != nil {
+		return err
+	}
+
+	if absSourcePth != absDestinationPth {
+		if err := command.CopyFile(absSourcePth, absDestinationPth); err != nil {
+			return err
+		}
+	}
+
+	return s.exportEnv(envKey, absDestinationPth)
+}
+
+// exportOutputFileContent writes the content to the destination and exports its path, like output.ExportOutputFileContent.
+func (s Step) exportOutputFileContent(content, destinationPth, envKey string) error {
+	if err := fileutil.WriteStringToFile(destinationPth, content); err != nil {
+		return err
+	}
+
+	return s.exportOutputFile(destinationPth, destinationPth, envKey)
+}
+
+// zipAndExportOutput zips the files or the directories to the destination and exports its path, like output.ZipAndExportOutput.
+func (s Step) zipAndExportOutput(sourcePths []string, destinationZipPth, envKey string) error {
+	if len(sourcePths) == 0 {
+		return fmt.Errorf("source path list (%s) is empty", sourcePths)
+	}
+
+	var dirCount int
+	for _, pth := range sourcePths {
+		isDir, err := pathutil.IsDirExists(pth)
+		if err != nil {
+			return err
+		}
+		if isDir {
+			dirCount++
+		}
+	}
+	if dirCount != 0 && dirCount != len(sourcePths) {
+		return fmt.Errorf("source path list (%s) contains a mix of files and folders", sourcePths)
+	}
+
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("__export_tmp_dir__")
+	if err != nil {
+		return err
+	}
+	tmpZipPth := filepath.Join(tmpDir, "temp-zip-file.zip")
+
+	if dirCount > 0 {
+		err = ziputil.ZipDirs(sourcePths, tmpZipPth)
+	} else {
+		err = ziputil.ZipFiles(sourcePths, tmpZipPth)
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.exportOutputFile(tmpZipPth, destinationZipPth, envKey)
+}
+
+// runJobs runs the jobs with at most concurrency jobs at a time, and returns their errors in the order of the jobs.
+func runJobs(concurrency, count int, job func(i int) error) []error {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	errs := make([]error, count)
+	semaphore := make(chan struct{}, concurrency)
+	var wg sync.WaitGroup
+	for i := 0; i < count; i++ {
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(i int) {
+			defer func() {
+				<-semaphore
+				wg.Done()
+			}()
+			errs[i] = job(i)
+		}(i)
+	}
+	wg.Wait()
+
+	return errs
+}
+
+// exportMethods exports the archive with every distribution method, concurrently if the export concurrency allows it.
+// The returned exports include the failed ones, the returned error is the first error in the order of the distribution methods.
+func (s Step) exportMethods(opts Config) ([]MethodExport, error) {
+	var exports []MethodExport
+	if opts.ExportConcurrency <= 1 || len(opts.DistributionMethods) <= 1 {
+		for _, distributionMethod := range opts.DistributionMethods {
+			export, err := s.exportArchive(opts, distributionMethod)
+			if export.DistributionMethod != "" {
+				exports = append(exports, export)
+			}
+			if err != nil {
+				return exports, err
+			}
+		}
+		return exports, nil
+	}
+
+	s.logger.Infof("Exporting %d distribution methods, %d at a time", len(opts.DistributionMethods), opts.ExportConcurrency)
+	s.logger.Println()
+
+	results := make([]MethodExport, len(opts.DistributionMethods))
+	errs := runJobs(opts.ExportConcurrency, len(opts.DistributionMethods), func(i int) error {
+		job, logger := s.newJob()
+		defer s.flushJob(logger)
+
+		var err error
+		results[i], err = job.exportArchive(opts, opts.DistributionMethods[i])
+		return err
+	})
+
+	var firstErr error
+	for i, export := range results {
+		if export.DistributionMethod != "" {
+			exports = append(exports, export)
+		}
+		if errs[i] != nil && firstErr == nil {
+			firstErr = errs[i]
+		}
+	}
+	return exports, firstErr
+}
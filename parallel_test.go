@@ -0,0 +1,103 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingLogger struct {
+	jobLogger
+	lines []string
+}
+
+func (l *recordingLogger) Infof(format string, v ...interface{}) {
+	l.lines = append(l.lines, "info: "+fmt.Sprintf(format, v...))
+}
+
+func (l *recordingLogger) Warnf(format string, v ...interface{}) {
+	l.lines = append(l.lines, "warn: "+fmt.Sprintf(format, v...))
+}
+
+func TestRunJobs(t *testing.T) {
+	// Given
+	var running, maxRunning int32
+	job := func(i int) error {
+		current := atomic.AddInt32(&running, 1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if current <= max || atomic.CompareAndSwapInt32(&maxRunning, max, current) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		atomic.AddInt32(&running, -1)
+
+		if i%2 == 1 {
+			return fmt.Errorf("job %d failed", i)
+		}
+		return nil
+	}
+
+	// When
+	errs := runJobs(2, 6, job)
+
+	// Then
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxRunning), int32(2))
+	assert.Equal(t, []error{nil, errors.New("job 1 failed"), nil, errors.New("job 3 failed"), nil, errors.New("job 5 failed")}, errs)
+}
+
+func TestJobLogger(t *testing.T) {
+	// Given
+	logger := &jobLogger{}
+	target := &recordingLogger{}
+	step := Step{logger: target, exclusive: &sync.Mutex{}}
+
+	// When
+	logger.Infof("Exporting %s", "app-store")
+	logger.Warnf("Profile expires soon")
+
+	// Then
+	assert.Empty(t, target.lines)
+	step.flushJob(logger)
+	assert.Equal(t, []string{"info: Exporting app-store", "warn: Profile expires soon"}, target.lines)
+	assert.Empty(t, logger.entries)
+}
+
+func TestZipAndExportOutput(t *testing.T) {
+	if _, err := exec.LookPath("rsync"); err != nil {
+		t.Skip("copying the zip requires rsync")
+	}
+
+	// Given
+	recordPath := fakeEnvman(t)
+	exclusive := &sync.Mutex{}
+	job, _ := Step{logger: &recordingLogger{}, exclusive: exclusive}.newJob()
+	sourceDir := t.TempDir()
+	var sourcePths []string
+	for _, name := range []string{"Sample.ipa", "Sample-Widget.ipa"} {
+		pth := filepath.Join(sourceDir, name)
+		assert.NoError(t, os.WriteFile(pth, []byte(name), 0600))
+		sourcePths = append(sourcePths, pth)
+	}
+	zipPath := filepath.Join(t.TempDir(), "ipas.zip")
+
+	// When
+	err := job.zipAndExportOutput(sourcePths, zipPath, "BITRISE_IPAS_PATH")
+
+	// Then
+	assert.NoError(t, err)
+	assert.FileExists(t, zipPath)
+	content, err := os.ReadFile(recordPath)
+	assert.NoError(t, err)
+	assert.Equal(t, "BITRISE_IPAS_PATH="+zipPath+"\n", string(content))
+	assert.True(t, exclusive.TryLock(), "the exclusive stage is released")
+}
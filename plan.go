@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"howett.net/plist"
+)
+
+// ExportPlan describes the code signing settings the archive is exported with.
+type ExportPlan struct {
+	ArchivePath string             `json:"archive_path"`
+	Exports     []ExportMethodPlan `json:"exports"`
+}
+
+// ExportMethodPlan describes the export with a single distribution method.
+type ExportMethodPlan struct {
+	DistributionMethod string             `json:"distribution_method"`
+	ExportOptionsPath  string             `json:"export_options_path"`
+	Targets            []ExportPlanTarget `json:"targets"`
+}
+
+// ExportPlanTarget describes how a single target of the archive is signed.
+type ExportPlanTarget struct {
+	BundleID              string `json:"bundle_id"`
+	ProfileName           string `json:"profile_name"`
+	ProfileUUID           string `json:"profile_uuid"`
+	CertificateCommonName string `json:"certificate_common_name"`
+	TeamID                string `json:"team_id"`
+	SigningStyle          string `json:"signing_style"`
+	ExportMethod          string `json:"export_method"`
+}
+
+func newExportMethodPlan(distributionMethod, exportOptionsPath string, bundleIDEntitlementsMap map[string]plistutil.PlistData, codeSigning ExportCodeSigning) ExportMethodPlan {
+	var bundleIDs []string
+	for bundleID := range bundleIDEntitlementsMap {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	var targets []ExportPlanTarget
+	for _, bundleID := range bundleIDs {
+		profile := codeSigning.Profiles[bundleID]
+
+		targets = append(targets, ExportPlanTarget{
+			BundleID:              bundleID,
+			ProfileName:           profile.Name,
+			ProfileUUID:           profile.UUID,
+			CertificateCommonName: codeSigning.Certificate.CommonName,
+			TeamID:                codeSigning.TeamID,
+			SigningStyle:          codeSigning.SigningStyle,
+			ExportMethod:          string(codeSigning.Method),
+		})
+	}
+
+	return ExportMethodPlan{
+		DistributionMethod: distributionMethod,
+		ExportOptionsPath:  exportOptionsPath,
+		Targets:            targets,
+	}
+}
+
+// exportOptionsUploads returns true if xcodebuild uploads the export to App Store Connect, instead of writing the IPA to the export dir.
+func exportOptionsUploads(content string) bool {
+	var options map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(content), &options); err != nil {
+		return false
+	}
+	return options[destinationKey] == destinationUpload
+}
+
+// codeSigningFromExportOptions reads the code signing settings of a user provided export options plist.
+// Certificates and provisioning profiles are referenced by name in export options, their other fields are left empty.
+func codeSigningFromExportOptions(content string) (ExportCodeSigning, error) {
+	var options map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(content), &options); err != nil {
+		return ExportCodeSigning{}, fmt.Errorf("failed to parse export options, error: %s", err)
+	}
+
+	codeSigning := ExportCodeSigning{
+		Profiles: map[string]profileutil.ProvisioningProfileInfoModel{},
+	}
+	if method, ok := options[exportoptions.MethodKey].(string); ok {
+		codeSigning.Method = exportoptions.Method(method)
+		if legacyMethod, err := parseExportMethod(method); err == nil {
+			codeSigning.Method = legacyMethod
+		}
+	}
+	if teamID, ok := options[exportoptions.TeamIDKey].(string); ok {
+		codeSigning.TeamID = teamID
+	}
+	if certificate, ok := options[exportoptions.SigningCertificateKey].(string); ok {
+		codeSigning.Certificate = certificateutil.CertificateInfoModel{CommonName: certificate}
+	}
+	if signingStyle, ok := options[exportoptions.SigningStyleKey].(string); ok {
+		codeSigning.SigningStyle = signingStyle
+	}
+	if profiles, ok := options[exportoptions.ProvisioningProfilesKey].(map[string]interface{}); ok {
+		for bundleID, profile := range profiles {
+			if name, ok := profile.(string); ok {
+				codeSigning.Profiles[bundleID] = profileutil.ProvisioningProfileInfoModel{Name: name}
+			}
+		}
+	}
+
+	return codeSigning, nil
+}
+
+func (plan ExportPlan) String() (string, error) {
+	data, err := json.MarshalIndent(plan, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal export plan, error: %s", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+)
+
+func TestNewExportMethodPlan(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	_, codeSigning, err := generator.generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+	assert.NoError(t, err)
+
+	// When
+	plan := newExportMethodPlan("app-store", "export_options.plist", archive.BundleIDEntitlementsMap(), codeSigning)
+
+	// Then
+	assert.Equal(t, ExportMethodPlan{
+		DistributionMethod: "app-store",
+		ExportOptionsPath:  "export_options.plist",
+		Targets: []ExportPlanTarget{
+			{
+				BundleID:              testBundleID,
+				ProfileName:           "Sample App Store",
+				ProfileUUID:           "app-store-uuid",
+				CertificateCommonName: "Apple Distribution: Bitrise Bot (72SA8V3WYL)",
+				TeamID:                testTeamID,
+				SigningStyle:          "manual",
+				ExportMethod:          "app-store",
+			},
+		},
+	}, plan)
+}
+
+func TestCodeSigningFromExportOptions(t *testing.T) {
+	// Given
+	content := readGoldenFile(t, "development_export_options.plist")
+
+	// When
+	codeSigning, err := codeSigningFromExportOptions(content)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, exportoptions.MethodDevelopment, codeSigning.Method)
+	assert.Equal(t, testTeamID, codeSigning.TeamID)
+	assert.Equal(t, "Apple Development: Bitrise Bot (E89JV3W9K4)", codeSigning.Certificate.CommonName)
+	assert.Equal(t, "Sample Development", codeSigning.Profiles[testBundleID].Name)
+}
+
+func TestExportOptionsUploads(t *testing.T) {
+	upload := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>method</key><string>app-store-connect</string><key>destination</key><string>upload</string></dict></plist>`
+
+	assert.True(t, exportOptionsUploads(upload))
+	assert.False(t, exportOptionsUploads(readGoldenFile(t, "development_export_options.plist")))
+}
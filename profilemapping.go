@@ -0,0 +1,135 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ryanuber/go-glob"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+// parseProfileMapping parses the bundle ID - provisioning profile (name or UUID) mapping.
+// The mapping is either a JSON object or lines in the form of: com.example.app.widget=Widget AdHoc
+func parseProfileMapping(content string) (map[string]string, error) {
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, nil
+	}
+
+	if strings.HasPrefix(content, "{") {
+		var mapping map[string]string
+		if err := json.Unmarshal([]byte(content), &mapping); err != nil {
+			return nil, fmt.Errorf("failed to parse JSON mapping, error: %s", err)
+		}
+		return mapping, nil
+	}
+
+	mapping := map[string]string{}
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+
+		split := strings.SplitN(line, "=", 2)
+		if len(split) != 2 || strings.TrimSpace(split[0]) == "" || strings.TrimSpace(split[1]) == "" {
+			return nil, fmt.Errorf("invalid line (%s), expected format: <bundle ID>=<profile name or UUID>", line)
+		}
+
+		bundleID, profile := strings.TrimSpace(split[0]), strings.TrimSpace(split[1])
+		if _, ok := mapping[bundleID]; ok {
+			return nil, fmt.Errorf("bundle ID (%s) is specified more than once", bundleID)
+		}
+		mapping[bundleID] = profile
+	}
+
+	return mapping, nil
+}
+
+// resolveMappedProfiles finds the profiles of the mapping and validates them against the archive's targets
+// and the export method.
+func resolveMappedProfiles(mapping map[string]string, profiles []profileutil.ProvisioningProfileInfoModel, bundleIDEntitlementsMap map[string]plistutil.PlistData, profileType profileutil.ProfileType, exportMethod exportoptions.Method) (map[string]profileutil.ProvisioningProfileInfoModel, error) {
+	var bundleIDs []string
+	for bundleID := range mapping {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+
+	mappedProfiles := map[string]profileutil.ProvisioningProfileInfoModel{}
+	for _, bundleID := range bundleIDs {
+		profileRef := mapping[bundleID]
+
+		entitlements, ok := bundleIDEntitlementsMap[bundleID]
+		if !ok {
+			return nil, fmt.Errorf("bundle ID (%s) of the profile mapping is not a target of the archive", bundleID)
+		}
+
+		var candidates []profileutil.ProvisioningProfileInfoModel
+		for _, profile := range profiles {
+			if profile.UUID == profileRef || profile.Name == profileRef {
+				candidates = append(candidates, profile)
+			}
+		}
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("provisioning profile (%s) mapped to (%s) is not installed", profileRef, bundleID)
+		}
+
+		var validationErr error
+		var selected *profileutil.ProvisioningProfileInfoModel
+		for i, profile := range candidates {
+			if !glob.Glob(profile.BundleID, bundleID) {
+				validationErr = fmt.Errorf("provisioning profile (%s) mapped to (%s) is for a different bundle ID: %s", profileRef, bundleID, profile.BundleID)
+				continue
+			}
+
+			if profile.ExportType != exportMethod {
+				validationErr = fmt.Errorf("provisioning profile (%s) mapped to (%s) is for the %s export method, not for %s", profileRef, bundleID, profile.ExportType, exportMethod)
+				continue
+			}
+
+			if missing := profileutil.MatchTargetAndProfileEntitlements(entitlements, profile.Entitlements, profileType); len(missing) > 0 {
+				validationErr = fmt.Errorf("provisioning profile (%s) mapped to (%s) is missing the entitlements: %s", profileRef, bundleID, strings.Join(missing, ", "))
+				continue
+			}
+
+			if selected == nil || profile.ExpirationDate.After(selected.ExpirationDate) {
+				selected = &candidates[i]
+			}
+		}
+		if selected == nil {
+			return nil, validationErr
+		}
+
+		mappedProfiles[bundleID] = *selected
+	}
+
+	return mappedProfiles, nil
+}
+
+// createMappedProfilesSelectableCodeSignGroupFilter removes the code sign groups whose certificate
+// is not included in every mapped profile.
+func createMappedProfilesSelectableCodeSignGroupFilter(mappedProfiles map[string]profileutil.ProvisioningProfileInfoModel) export.SelectableCodeSignGroupFilter {
+	return func(group *export.SelectableCodeSignGroup) bool {
+		for _, profile := range mappedProfiles {
+			if !profileContainsCertificate(profile, group.Certificate) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func profileContainsCertificate(profile profileutil.ProvisioningProfileInfoModel, certificate certificateutil.CertificateInfoModel) bool {
+	for _, cert := range profile.DeveloperCertificates {
+		if cert.Serial == certificate.Serial {
+			return true
+		}
+	}
+	return false
+}
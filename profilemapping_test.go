@@ -0,0 +1,148 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+func TestParseProfileMapping(t *testing.T) {
+	want := map[string]string{
+		"io.bitrise.sample":        "Sample AdHoc",
+		"io.bitrise.sample.widget": "widget-uuid",
+	}
+
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name:    "lines",
+			content: "io.bitrise.sample=Sample AdHoc\n\n io.bitrise.sample.widget = widget-uuid\n",
+		},
+		{
+			name:    "JSON",
+			content: `{"io.bitrise.sample": "Sample AdHoc", "io.bitrise.sample.widget": "widget-uuid"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseProfileMapping(tt.content)
+
+			assert.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestParseProfileMapping_invalid(t *testing.T) {
+	for _, content := range []string{"io.bitrise.sample", "=Sample AdHoc", "a=b\na=c", "{"} {
+		_, err := parseProfileMapping(content)
+		assert.Error(t, err, content)
+	}
+}
+
+func TestResolveMappedProfiles(t *testing.T) {
+	certificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	profile := testProfile("Sample AdHoc", "ad-hoc-uuid", exportoptions.MethodAdHoc, certificate)
+	otherProfile := testProfile("Other AdHoc", "other-ad-hoc-uuid", exportoptions.MethodAdHoc, certificate)
+	otherProfile.BundleID = "io.bitrise.other"
+	developmentProfile := testProfile("Sample Development", "development-uuid", exportoptions.MethodDevelopment, certificate)
+	profiles := []profileutil.ProvisioningProfileInfoModel{profile, otherProfile, developmentProfile}
+
+	tests := []struct {
+		name    string
+		mapping map[string]string
+		wantErr string
+	}{
+		{
+			name:    "by name",
+			mapping: map[string]string{testBundleID: "Sample AdHoc"},
+		},
+		{
+			name:    "by UUID",
+			mapping: map[string]string{testBundleID: "ad-hoc-uuid"},
+		},
+		{
+			name:    "not installed",
+			mapping: map[string]string{testBundleID: "Missing"},
+			wantErr: "provisioning profile (Missing) mapped to (io.bitrise.sample) is not installed",
+		},
+		{
+			name:    "not a target",
+			mapping: map[string]string{"io.bitrise.other": "Other AdHoc"},
+			wantErr: "bundle ID (io.bitrise.other) of the profile mapping is not a target of the archive",
+		},
+		{
+			name:    "bundle ID mismatch",
+			mapping: map[string]string{testBundleID: "Other AdHoc"},
+			wantErr: "provisioning profile (Other AdHoc) mapped to (io.bitrise.sample) is for a different bundle ID: io.bitrise.other",
+		},
+		{
+			name:    "export method mismatch",
+			mapping: map[string]string{testBundleID: "Sample Development"},
+			wantErr: "provisioning profile (Sample Development) mapped to (io.bitrise.sample) is for the development export method, not for ad-hoc",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entitlements := map[string]plistutil.PlistData{testBundleID: {}}
+
+			got, err := resolveMappedProfiles(tt.mapping, profiles, entitlements, profileutil.ProfileTypeIos, exportoptions.MethodAdHoc)
+
+			if tt.wantErr != "" {
+				assert.EqualError(t, err, tt.wantErr)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, "ad-hoc-uuid", got[testBundleID].UUID)
+		})
+	}
+}
+
+func TestResolveMappedProfiles_missingEntitlements(t *testing.T) {
+	// Given
+	profile := testProfile("Sample AdHoc", "ad-hoc-uuid", exportoptions.MethodAdHoc)
+	entitlements := map[string]plistutil.PlistData{testBundleID: {"aps-environment": "production"}}
+
+	// When
+	_, err := resolveMappedProfiles(map[string]string{testBundleID: "Sample AdHoc"}, []profileutil.ProvisioningProfileInfoModel{profile}, entitlements, profileutil.ProfileTypeIos, exportoptions.MethodAdHoc)
+
+	// Then
+	assert.EqualError(t, err, "provisioning profile (Sample AdHoc) mapped to (io.bitrise.sample) is missing the entitlements: aps-environment")
+}
+
+func TestConfig_generateExportOptions_plist_profileMapping(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	mappedProfile := testProfile("Sample Development Mapped", "mapped-uuid", exportoptions.MethodDevelopment, codesignAssets.certificates[0])
+	codesignAssets.profiles = append(codesignAssets.profiles, mappedProfile)
+	archive := testArchive(codesignAssets.profiles[0])
+	generator := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, map[string]string{testBundleID: "mapped-uuid"}, false)
+
+	// When
+	_, codeSigning, err := generator.generateExportOptionsPlist("development", archive, testExportOptionsConfig())
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "mapped-uuid", codeSigning.Profiles[testBundleID].UUID)
+	assert.Equal(t, codesignAssets.certificates[0], codeSigning.Certificate)
+}
+
+func TestConfig_generateExportOptions_plist_profileMappingExportMethodMismatch(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	mappedProfile := testProfile("Sample AdHoc", "ad-hoc-uuid", exportoptions.MethodAdHoc, codesignAssets.certificates[1])
+	codesignAssets.profiles = append(codesignAssets.profiles, mappedProfile)
+	archive := testArchive(codesignAssets.profiles[0])
+	generator := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, map[string]string{testBundleID: "Sample AdHoc"}, false)
+
+	// When
+	_, _, err := generator.generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+
+	// Then
+	assert.EqualError(t, err, "invalid provisioning profile mapping: provisioning profile (Sample AdHoc) mapped to (io.bitrise.sample) is for the ad-hoc export method, not for app-store")
+}
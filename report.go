@@ -0,0 +1,261 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/models"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+	"howett.net/plist"
+)
+
+// ExportReport is the machine-readable summary of the step run.
+type ExportReport struct {
+	ArchivePath       string               `json:"archive_path"`
+	BundleID          string               `json:"bundle_id"`
+	Version           string               `json:"version"`
+	BuildNumber       string               `json:"build_number"`
+	XcodebuildVersion string               `json:"xcodebuild_version"`
+	Targets           []ExportReportTarget `json:"targets"`
+	Exports           []ExportReportExport `json:"exports"`
+	DSYMs             []ExportReportDSYM   `json:"dsyms"`
+}
+
+// ExportReportTarget is an application or extension of the archive.
+type ExportReportTarget struct {
+	BundleID       string               `json:"bundle_id"`
+	Version        string               `json:"version"`
+	BuildNumber    string               `json:"build_number"`
+	ArchiveProfile *ExportReportProfile `json:"archive_profile"`
+}
+
+// ExportReportExport is the export with a single distribution method.
+type ExportReportExport struct {
+	DistributionMethod string                  `json:"distribution_method"`
+	ExportOptions      map[string]interface{}  `json:"export_options"`
+	SigningStyle       string                  `json:"signing_style"`
+	TeamID             string                  `json:"team_id"`
+	Certificate        ExportReportCertificate `json:"certificate"`
+	// InstallerCertificate is only set for macOS App Store exports.
+	InstallerCertificate *ExportReportCertificate `json:"installer_certificate,omitempty"`
+	Profiles             []ExportReportProfile    `json:"profiles"`
+	Artifacts            []ExportReportArtifact   `json:"artifacts"`
+}
+
+// ExportReportCertificate ...
+type ExportReportCertificate struct {
+	CommonName     string     `json:"common_name"`
+	Serial         string     `json:"serial"`
+	TeamID         string     `json:"team_id"`
+	ExpirationDate *time.Time `json:"expiration_date"`
+}
+
+// ExportReportProfile ...
+type ExportReportProfile struct {
+	BundleID       string     `json:"bundle_id"`
+	Name           string     `json:"name"`
+	UUID           string     `json:"uuid"`
+	TeamID         string     `json:"team_id"`
+	ExportType     string     `json:"export_type"`
+	ExpirationDate *time.Time `json:"expiration_date"`
+}
+
+// ExportReportArtifact ...
+type ExportReportArtifact struct {
+	Path   string `json:"path"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// ExportReportDSYM ...
+type ExportReportDSYM struct {
+	Path  string       `json:"path"`
+	UUIDs []BinaryUUID `json:"uuids"`
+}
+
+// newExportReport creates the report of an archive, archiveInfoPlist is the Info.plist of the .xcarchive.
+func newExportReport(archivePath string, archiveInfoPlist plistutil.PlistData, targets []ExportReportTarget, xcodebuildVersion models.XcodebuildVersionModel) ExportReport {
+	report := ExportReport{
+		ArchivePath:       archivePath,
+		XcodebuildVersion: fmt.Sprintf("%s (%s)", xcodebuildVersion.Version, xcodebuildVersion.BuildVersion),
+		Targets:           targets,
+	}
+
+	if properties, found := archiveInfoPlist.GetMapStringInterface("ApplicationProperties"); found {
+		report.BundleID, _ = properties.GetString("CFBundleIdentifier")
+		report.Version, _ = properties.GetString("CFBundleShortVersionString")
+		report.BuildNumber, _ = properties.GetString("CFBundleVersion")
+	}
+
+	return report
+}
+
+func iosArchiveReportTargets(archive xcarchive.IosArchive) []ExportReportTarget {
+	var targets []ExportReportTarget
+	for _, app := range archiveApplications(archive) {
+		var profile *profileutil.ProvisioningProfileInfoModel
+		if app.ProvisioningProfile.UUID != "" {
+			profile = &app.ProvisioningProfile
+		}
+		targets = append(targets, newExportReportTarget(app.InfoPlist, profile))
+	}
+	return targets
+}
+
+func newExportReportTarget(infoPlist plistutil.PlistData, archiveProfile *profileutil.ProvisioningProfileInfoModel) ExportReportTarget {
+	target := ExportReportTarget{
+		BundleID:    stringValue(infoPlist, "CFBundleIdentifier"),
+		Version:     stringValue(infoPlist, "CFBundleShortVersionString"),
+		BuildNumber: stringValue(infoPlist, "CFBundleVersion"),
+	}
+	if archiveProfile != nil {
+		profile := newExportReportProfile(target.BundleID, *archiveProfile)
+		target.ArchiveProfile = &profile
+	}
+	return target
+}
+
+func (report *ExportReport) addExport(export MethodExport, artifactPths []string) error {
+	reportExport := ExportReportExport{
+		DistributionMethod: export.DistributionMethod,
+		SigningStyle:       export.CodeSigning.SigningStyle,
+		TeamID:             export.CodeSigning.TeamID,
+		Certificate:        newExportReportCertificate(export.CodeSigning.Certificate),
+	}
+	if export.CodeSigning.InstallerCertificate.CommonName != "" {
+		installerCertificate := newExportReportCertificate(export.CodeSigning.InstallerCertificate)
+		reportExport.InstallerCertificate = &installerCertificate
+	}
+
+	if export.Plan.ExportOptionsPath != "" {
+		content, err := os.ReadFile(export.Plan.ExportOptionsPath)
+		if err != nil {
+			return fmt.Errorf("failed to read export options, error: %s", err)
+		}
+
+		var exportOptions map[string]interface{}
+		if _, err := plist.Unmarshal(content, &exportOptions); err != nil {
+			return fmt.Errorf("failed to parse export options, error: %s", err)
+		}
+		reportExport.ExportOptions = exportOptions
+	}
+
+	for _, target := range export.Plan.Targets {
+		if profile, ok := export.CodeSigning.Profiles[target.BundleID]; ok {
+			reportExport.Profiles = append(reportExport.Profiles, newExportReportProfile(target.BundleID, profile))
+		}
+	}
+
+	for _, pth := range artifactPths {
+		artifact, err := newExportReportArtifact(pth)
+		if err != nil {
+			return err
+		}
+		reportExport.Artifacts = append(reportExport.Artifacts, artifact)
+	}
+
+	report.Exports = append(report.Exports, reportExport)
+
+	return nil
+}
+
+func (report ExportReport) String() (string, error) {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal export report, error: %s", err)
+	}
+	return string(data), nil
+}
+
+func newExportReportCertificate(certificate certificateutil.CertificateInfoModel) ExportReportCertificate {
+	reportCertificate := ExportReportCertificate{
+		CommonName: certificate.CommonName,
+		Serial:     certificate.Serial,
+		TeamID:     certificate.TeamID,
+	}
+	if !certificate.EndDate.IsZero() {
+		expirationDate := certificate.EndDate
+		reportCertificate.ExpirationDate = &expirationDate
+	}
+	return reportCertificate
+}
+
+func newExportReportProfile(bundleID string, profile profileutil.ProvisioningProfileInfoModel) ExportReportProfile {
+	reportProfile := ExportReportProfile{
+		BundleID:   bundleID,
+		Name:       profile.Name,
+		UUID:       profile.UUID,
+		TeamID:     profile.TeamID,
+		ExportType: string(profile.ExportType),
+	}
+	if !profile.ExpirationDate.IsZero() {
+		expirationDate := profile.ExpirationDate
+		reportProfile.ExpirationDate = &expirationDate
+	}
+	return reportProfile
+}
+
+func newExportReportArtifact(pth string) (ExportReportArtifact, error) {
+	file, err := os.Open(pth)
+	if err != nil {
+		return ExportReportArtifact{}, err
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Errorf("Failed to close file (%s), error: %s", pth, err)
+		}
+	}()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return ExportReportArtifact{}, fmt.Errorf("failed to calculate checksum of (%s), error: %s", pth, err)
+	}
+
+	return ExportReportArtifact{
+		Path:   pth,
+		Size:   size,
+		SHA256: hex.EncodeToString(hash.Sum(nil)),
+	}, nil
+}
+
+// archiveApplications returns the main application and every embedded application and extension of the archive.
+func archiveApplications(archive xcarchive.IosArchive) []v1xcarchive.IosBaseApplication {
+	app := archive.Application
+	apps := []v1xcarchive.IosBaseApplication{app.IosBaseApplication}
+
+	for _, extension := range app.Extensions {
+		apps = append(apps, extension.IosBaseApplication)
+	}
+
+	if app.WatchApplication != nil {
+		apps = append(apps, app.WatchApplication.IosBaseApplication)
+		for _, extension := range app.WatchApplication.Extensions {
+			apps = append(apps, extension.IosBaseApplication)
+		}
+	}
+
+	if app.ClipApplication != nil {
+		apps = append(apps, app.ClipApplication.IosBaseApplication)
+		for _, extension := range app.ClipApplication.Extensions {
+			apps = append(apps, extension.IosBaseApplication)
+		}
+	}
+
+	return apps
+}
+
+func stringValue(data plistutil.PlistData, key string) string {
+	value, _ := data.GetString(key)
+	return value
+}
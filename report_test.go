@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/models"
+)
+
+func TestExportReport_addExport(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	exportOptionsContent, codeSigning, err := generator.generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+	assert.NoError(t, err)
+
+	tmpDir := t.TempDir()
+	exportOptionsPath := filepath.Join(tmpDir, "export_options.plist")
+	assert.NoError(t, os.WriteFile(exportOptionsPath, []byte(exportOptionsContent), 0600))
+	ipaPath := filepath.Join(tmpDir, "sample.ipa")
+	assert.NoError(t, os.WriteFile(ipaPath, []byte("ipa"), 0600))
+
+	export := MethodExport{
+		DistributionMethod: "app-store",
+		Plan:               newExportMethodPlan("app-store", exportOptionsPath, archive.BundleIDEntitlementsMap(), codeSigning),
+		CodeSigning:        codeSigning,
+	}
+	report := newExportReport(archive.Path, archive.InfoPlist, iosArchiveReportTargets(archive), models.XcodebuildVersionModel{Version: "Xcode 15.0", BuildVersion: "15A240d"})
+
+	// When
+	err = report.addExport(export, []string{ipaPath})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, "Xcode 15.0 (15A240d)", report.XcodebuildVersion)
+	assert.Equal(t, 1, len(report.Targets))
+	assert.Equal(t, "development-uuid", report.Targets[0].ArchiveProfile.UUID)
+
+	assert.Equal(t, 1, len(report.Exports))
+	reportExport := report.Exports[0]
+	assert.Equal(t, "app-store", reportExport.ExportOptions["method"])
+	assert.Equal(t, "2", reportExport.Certificate.Serial)
+	assert.Equal(t, []ExportReportProfile{newExportReportProfile(testBundleID, testCodesignAssets().profiles[1])}, reportExport.Profiles)
+	assert.Equal(t, []ExportReportArtifact{{
+		Path:   ipaPath,
+		Size:   3,
+		SHA256: "78324857e8d9bfa749dc301271df54a6572de9f4c3df8a9507cfa7b7d2b25f8e",
+	}}, reportExport.Artifacts)
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/models"
+	"howett.net/plist"
+)
+
+// Export options value types
+const (
+	exportOptionTypeString     = "string"
+	exportOptionTypeBool       = "boolean"
+	exportOptionTypeDictionary = "dictionary"
+)
+
+// exportOptionSchema describes a key of the export options plist, as documented by `xcodebuild -help`.
+type exportOptionSchema struct {
+	Type string
+	// AllowedValues is empty if any value of the type is allowed.
+	AllowedValues []string
+	// MinXcodeMajorVersion is 0 if the key is supported by every Xcode version the step supports.
+	MinXcodeMajorVersion int64
+	// MinXcodeMinorVersion is the minor version of the minimum Xcode version, for example 3 for Xcode 15.3.
+	MinXcodeMinorVersion int64
+	// Keys lists the known keys of a dictionary value, nil if any key is allowed.
+	Keys map[string]exportOptionSchema
+	// ValueType is the type of the values of a dictionary with arbitrary keys.
+	ValueType string
+}
+
+var exportOptionsSchema = map[string]exportOptionSchema{
+	exportoptions.CompileBitcodeKey:                           {Type: exportOptionTypeBool},
+	destinationKey:                                            {Type: exportOptionTypeString, AllowedValues: []string{destinationExport, destinationUpload}, MinXcodeMajorVersion: 15, MinXcodeMinorVersion: 3},
+	exportoptions.DistributionBundleIdentifier:                {Type: exportOptionTypeString},
+	exportoptions.EmbedOnDemandResourcesAssetPacksInBundleKey: {Type: exportOptionTypeBool},
+	"generateAppStoreInformation":                             {Type: exportOptionTypeBool},
+	exportoptions.ICloudContainerEnvironmentKey: {
+		Type: exportOptionTypeString,
+		AllowedValues: []string{
+			string(exportoptions.ICloudContainerEnvironmentDevelopment),
+			string(exportoptions.ICloudContainerEnvironmentProduction),
+		},
+	},
+	exportoptions.InstallerSigningCertificateKey: {Type: exportOptionTypeString},
+	"manageAppVersionAndBuildNumber":             {Type: exportOptionTypeBool, MinXcodeMajorVersion: 13},
+	exportoptions.ManifestKey: {
+		Type: exportOptionTypeDictionary,
+		Keys: map[string]exportOptionSchema{
+			exportoptions.ManifestAppURLKey:               {Type: exportOptionTypeString},
+			exportoptions.ManifestDisplayImageURLKey:      {Type: exportOptionTypeString},
+			exportoptions.ManifestFullSizeImageURLKey:     {Type: exportOptionTypeString},
+			exportoptions.ManifestAssetPackManifestURLKey: {Type: exportOptionTypeString},
+		},
+	},
+	exportoptions.MethodKey: {
+		Type: exportOptionTypeString,
+		AllowedValues: []string{
+			string(exportoptions.MethodAppStore),
+			string(exportoptions.MethodAdHoc),
+			string(exportoptions.MethodPackage),
+			string(exportoptions.MethodEnterprise),
+			string(exportoptions.MethodDevelopment),
+			string(exportoptions.MethodDeveloperID),
+			exportMethodAppStoreConnect,
+			exportMethodReleaseTesting,
+			exportMethodDebugging,
+			"mac-application",
+			"validation",
+		},
+	},
+	"onDemandInstallCapable":                            {Type: exportOptionTypeBool},
+	exportoptions.OnDemandResourcesAssetPacksBaseURLKey: {Type: exportOptionTypeString},
+	exportoptions.ProvisioningProfilesKey:               {Type: exportOptionTypeDictionary, ValueType: exportOptionTypeString},
+	exportoptions.SigningCertificateKey:                 {Type: exportOptionTypeString},
+	exportoptions.SigningStyleKey:                       {Type: exportOptionTypeString, AllowedValues: []string{"manual", "automatic"}},
+	stripSwiftSymbolsKey:                                {Type: exportOptionTypeBool, MinXcodeMajorVersion: 15, MinXcodeMinorVersion: 3},
+	exportoptions.TeamIDKey:                             {Type: exportOptionTypeString},
+	testFlightInternalTestingOnlyKey:                    {Type: exportOptionTypeBool, MinXcodeMajorVersion: 15, MinXcodeMinorVersion: 3},
+	// thinning is either one of the constants or a device model identifier, for example: iPhone15,2
+	exportoptions.ThinningKey:      {Type: exportOptionTypeString},
+	exportoptions.UploadBitcodeKey: {Type: exportOptionTypeBool},
+	exportoptions.UploadSymbolsKey: {Type: exportOptionTypeBool},
+}
+
+// minXcodeVersion returns the minimum Xcode version of the key, for example: 13 or 15.3
+func (s exportOptionSchema) minXcodeVersion() string {
+	if s.MinXcodeMinorVersion == 0 {
+		return fmt.Sprintf("%d", s.MinXcodeMajorVersion)
+	}
+	return fmt.Sprintf("%d.%d", s.MinXcodeMajorVersion, s.MinXcodeMinorVersion)
+}
+
+// exportOptionsIssue is a problem of a single export options key.
+type exportOptionsIssue struct {
+	Key     string
+	Message string
+	// IsError is false if xcodebuild is expected to ignore the key.
+	IsError bool
+}
+
+func (i exportOptionsIssue) String() string {
+	return fmt.Sprintf("%s: %s", i.Key, i.Message)
+}
+
+// validateExportOptions checks the keys, value types and values of the export options against the known xcodebuild keys.
+func validateExportOptions(content string, xcodebuildVersion models.XcodebuildVersionModel) ([]exportOptionsIssue, error) {
+	var options map[string]interface{}
+	if _, err := plist.Unmarshal([]byte(content), &options); err != nil {
+		return nil, fmt.Errorf("failed to parse export options, error: %s", err)
+	}
+
+	return validateExportOptionsDictionary("", options, exportOptionsSchema, xcodebuildVersion), nil
+}
+
+func validateExportOptionsDictionary(keyPrefix string, options map[string]interface{}, schema map[string]exportOptionSchema, xcodebuildVersion models.XcodebuildVersionModel) []exportOptionsIssue {
+	var keys []string
+	for key := range options {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var issues []exportOptionsIssue
+	for _, key := range keys {
+		value := options[key]
+		path := keyPrefix + key
+
+		keySchema, ok := schema[key]
+		if !ok {
+			if suggestion := similarExportOptionsKey(key, schema); suggestion != "" {
+				issues = append(issues, exportOptionsIssue{Key: path, Message: fmt.Sprintf("unknown key, did you mean %s?", keyPrefix+suggestion), IsError: true})
+			} else {
+				issues = append(issues, exportOptionsIssue{Key: path, Message: "unknown key, xcodebuild might ignore it"})
+			}
+			continue
+		}
+
+		if keySchema.MinXcodeMajorVersion > 0 && !isXcodeVersionAtLeast(xcodebuildVersion, keySchema.MinXcodeMajorVersion, keySchema.MinXcodeMinorVersion) {
+			issues = append(issues, exportOptionsIssue{Key: path, Message: fmt.Sprintf("requires Xcode %s or later, xcodebuild might ignore it", keySchema.minXcodeVersion())})
+		}
+
+		if valueType := exportOptionValueType(value); valueType != keySchema.Type {
+			issues = append(issues, exportOptionsIssue{Key: path, Message: fmt.Sprintf("invalid type (%s), expected: %s", valueType, keySchema.Type), IsError: true})
+			continue
+		}
+
+		if len(keySchema.AllowedValues) > 0 {
+			if s, ok := value.(string); ok && !sliceutil.IsStringInSlice(s, keySchema.AllowedValues) {
+				issues = append(issues, exportOptionsIssue{Key: path, Message: fmt.Sprintf("invalid value (%s), allowed values: %s", s, strings.Join(keySchema.AllowedValues, ", ")), IsError: true})
+			}
+		}
+
+		dict, ok := value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if keySchema.Keys != nil {
+			issues = append(issues, validateExportOptionsDictionary(path+".", dict, keySchema.Keys, xcodebuildVersion)...)
+		} else if keySchema.ValueType != "" {
+			var dictKeys []string
+			for dictKey := range dict {
+				dictKeys = append(dictKeys, dictKey)
+			}
+			sort.Strings(dictKeys)
+
+			for _, dictKey := range dictKeys {
+				if valueType := exportOptionValueType(dict[dictKey]); valueType != keySchema.ValueType {
+					issues = append(issues, exportOptionsIssue{Key: path + "." + dictKey, Message: fmt.Sprintf("invalid type (%s), expected: %s", valueType, keySchema.ValueType), IsError: true})
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+func exportOptionValueType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return exportOptionTypeString
+	case bool:
+		return exportOptionTypeBool
+	case map[string]interface{}:
+		return exportOptionTypeDictionary
+	case []interface{}:
+		return "array"
+	case int64, uint64, float32, float64:
+		return "number"
+	default:
+		return fmt.Sprintf("%T", value)
+	}
+}
+
+// similarExportOptionsKey returns the known key the unknown key is most likely a typo of, or an empty string.
+func similarExportOptionsKey(key string, schema map[string]exportOptionSchema) string {
+	var knownKeys []string
+	for knownKey := range schema {
+		knownKeys = append(knownKeys, knownKey)
+	}
+	sort.Strings(knownKeys)
+
+	suggestion := ""
+	bestDistance := 3
+	for _, knownKey := range knownKeys {
+		if strings.EqualFold(key, knownKey) {
+			return knownKey
+		}
+		if distance := levenshteinDistance(strings.ToLower(key), strings.ToLower(knownKey)); distance < bestDistance {
+			suggestion, bestDistance = knownKey, distance
+		}
+	}
+	return suggestion
+}
+
+func levenshteinDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current := make([]int, len(b)+1)
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous = current
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(values ...int) int {
+	min := values[0]
+	for _, v := range values[1:] {
+		if v < min {
+			min = v
+		}
+	}
+	return min
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/models"
+)
+
+func TestValidateExportOptions(t *testing.T) {
+	// Given
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>method</key>
+		<string>appstore</string>
+		<key>provisioningProfile</key>
+		<dict>
+			<key>io.bitrise.sample</key>
+			<string>Sample App Store</string>
+		</dict>
+		<key>uploadSymbols</key>
+		<string>YES</string>
+		<key>manageAppVersionAndBuildNumber</key>
+		<false/>
+		<key>manifest</key>
+		<dict>
+			<key>appURL</key>
+			<string>https://example.com/sample.ipa</string>
+			<key>softwarePackageURL</key>
+			<string>https://example.com/sample.ipa</string>
+		</dict>
+		<key>teamID</key>
+		<string>72SA8V3WYL</string>
+	</dict>
+</plist>`
+
+	// When
+	issues, err := validateExportOptions(content, models.XcodebuildVersionModel{Version: "Xcode 12.5", MajorVersion: 12})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []exportOptionsIssue{
+		{Key: "manageAppVersionAndBuildNumber", Message: "requires Xcode 13 or later, xcodebuild might ignore it"},
+		{Key: "manifest.softwarePackageURL", Message: "unknown key, xcodebuild might ignore it"},
+		{Key: "method", Message: "invalid value (appstore), allowed values: app-store, ad-hoc, package, enterprise, development, developer-id, app-store-connect, release-testing, debugging, mac-application, validation", IsError: true},
+		{Key: "provisioningProfile", Message: "unknown key, did you mean provisioningProfiles?", IsError: true},
+		{Key: "uploadSymbols", Message: "invalid type (string), expected: boolean", IsError: true},
+	}, issues)
+}
+
+func TestValidateExportOptions_valid(t *testing.T) {
+	for _, name := range []string{"app_store_export_options.plist", "development_export_options.plist"} {
+		// When
+		issues, err := validateExportOptions(readGoldenFile(t, name), models.XcodebuildVersionModel{Version: "Xcode 15.0", MajorVersion: 15})
+
+		// Then
+		assert.NoError(t, err)
+		assert.Empty(t, issues, name)
+	}
+}
+
+func TestValidateExportOptions_xcode153Keys(t *testing.T) {
+	// Given
+	content := `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+	<dict>
+		<key>destination</key>
+		<string>upload</string>
+		<key>method</key>
+		<string>app-store-connect</string>
+		<key>stripSwiftSymbols</key>
+		<false/>
+		<key>testFlightInternalTestingOnly</key>
+		<true/>
+	</dict>
+</plist>`
+
+	// When
+	issues, err := validateExportOptions(content, models.XcodebuildVersionModel{Version: "Xcode 15.2", MajorVersion: 15})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []exportOptionsIssue{
+		{Key: "destination", Message: "requires Xcode 15.3 or later, xcodebuild might ignore it"},
+		{Key: "stripSwiftSymbols", Message: "requires Xcode 15.3 or later, xcodebuild might ignore it"},
+		{Key: "testFlightInternalTestingOnly", Message: "requires Xcode 15.3 or later, xcodebuild might ignore it"},
+	}, issues)
+
+	// When
+	issues, err = validateExportOptions(content, models.XcodebuildVersionModel{Version: "Xcode 15.3", MajorVersion: 15})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Empty(t, issues)
+}
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/teamlapse/go-xcode/export"
+)
+
+// Code sign group selection criteria
+const (
+	selectionCriterionPreferredAssets = "preferred-assets"
+	selectionCriterionArchiveIdentity = "archive-identity"
+	selectionCriterionNonWildcard     = "non-wildcard"
+	selectionCriterionValidity        = "validity"
+	selectionCriterionTeam            = "team"
+)
+
+var selectionCriteria = []string{
+	selectionCriterionPreferredAssets,
+	selectionCriterionArchiveIdentity,
+	selectionCriterionNonWildcard,
+	selectionCriterionValidity,
+	selectionCriterionTeam,
+}
+
+// codeSignGroupSelectionPolicy ranks the code sign groups left after filtering.
+// The criteria are compared in order, the first criterion the groups differ in decides.
+type codeSignGroupSelectionPolicy struct {
+	Criteria []string
+	// PreferredAssets lists certificate common names and provisioning profile UUIDs to prefer.
+	PreferredAssets []string
+}
+
+func parseCodeSignGroupSelectionPolicy(criteriaList, preferredAssetsList string) (codeSignGroupSelectionPolicy, error) {
+	var criteria []string
+	for _, item := range strings.Split(criteriaList, "|") {
+		criterion := strings.TrimSpace(item)
+		if criterion == "" {
+			continue
+		}
+
+		if !sliceutil.IsStringInSlice(criterion, selectionCriteria) {
+			return codeSignGroupSelectionPolicy{}, fmt.Errorf("unknown criterion (%s), supported criteria: %s", criterion, strings.Join(selectionCriteria, ", "))
+		}
+		if sliceutil.IsStringInSlice(criterion, criteria) {
+			return codeSignGroupSelectionPolicy{}, fmt.Errorf("criterion (%s) is specified more than once", criterion)
+		}
+		criteria = append(criteria, criterion)
+	}
+
+	var preferredAssets []string
+	for _, item := range strings.Split(preferredAssetsList, "|") {
+		if asset := strings.TrimSpace(item); asset != "" {
+			preferredAssets = append(preferredAssets, asset)
+		}
+	}
+
+	return codeSignGroupSelectionPolicy{
+		Criteria:        criteria,
+		PreferredAssets: preferredAssets,
+	}, nil
+}
+
+// codeSignGroupSelection holds the archive specific inputs of the selection policy.
+type codeSignGroupSelection struct {
+	policy                 codeSignGroupSelectionPolicy
+	archiveSigningIdentity string
+	preferredTeamID        string
+}
+
+type rankedCodeSignGroup struct {
+	group  export.CodeSignGroup
+	scores []int64
+	// key is the stable tie-breaker: the certificate serial and the sorted profile UUIDs.
+	key string
+}
+
+// selectGroup returns the best ranked code sign group and logs the ranking.
+func (s codeSignGroupSelection) selectGroup(groups []export.CodeSignGroup) export.CodeSignGroup {
+	if len(groups) == 1 {
+		return groups[0]
+	}
+
+	var ranked []rankedCodeSignGroup
+	for _, group := range groups {
+		var scores []int64
+		for _, criterion := range s.policy.Criteria {
+			scores = append(scores, s.score(criterion, group))
+		}
+
+		ranked = append(ranked, rankedCodeSignGroup{
+			group:  group,
+			scores: scores,
+			key:    codeSignGroupKey(group),
+		})
+	}
+
+	sort.SliceStable(ranked, func(i, j int) bool {
+		for k := range s.policy.Criteria {
+			if ranked[i].scores[k] != ranked[j].scores[k] {
+				return ranked[i].scores[k] > ranked[j].scores[k]
+			}
+		}
+		return ranked[i].key < ranked[j].key
+	})
+
+	log.Warnf("Multiple code signing groups found, ranking them by: %s", strings.Join(s.policy.Criteria, ", "))
+	for i, r := range ranked {
+		log.Printf("%d. %s (%s): %s", i+1, r.group.Certificate().CommonName, r.group.Certificate().Serial, s.describeScores(r.scores))
+	}
+
+	winner, runnerUp := ranked[0], ranked[1]
+	reason := "certificate serial and profile UUIDs (tie-breaker)"
+	for k, criterion := range s.policy.Criteria {
+		if winner.scores[k] != runnerUp.scores[k] {
+			reason = criterion
+			break
+		}
+	}
+	log.Printf("Selected code signing group: %s (%s), decided by: %s", winner.group.Certificate().CommonName, winner.group.Certificate().Serial, reason)
+
+	return winner.group
+}
+
+func (s codeSignGroupSelection) score(criterion string, group export.CodeSignGroup) int64 {
+	certificate := group.Certificate()
+	profiles := group.BundleIDProfileMap()
+
+	switch criterion {
+	case selectionCriterionPreferredAssets:
+		var score int64
+		if sliceutil.IsStringInSlice(certificate.CommonName, s.policy.PreferredAssets) {
+			score++
+		}
+		for _, profile := range profiles {
+			if sliceutil.IsStringInSlice(profile.UUID, s.policy.PreferredAssets) {
+				score++
+			}
+		}
+		return score
+	case selectionCriterionArchiveIdentity:
+		if s.archiveSigningIdentity != "" && certificate.CommonName == s.archiveSigningIdentity {
+			return 1
+		}
+		return 0
+	case selectionCriterionNonWildcard:
+		var score int64
+		for _, profile := range profiles {
+			if !strings.Contains(profile.BundleID, "*") {
+				score++
+			}
+		}
+		return score
+	case selectionCriterionValidity:
+		expiration := certificate.EndDate
+		for _, profile := range profiles {
+			if expiration.IsZero() || (!profile.ExpirationDate.IsZero() && profile.ExpirationDate.Before(expiration)) {
+				expiration = profile.ExpirationDate
+			}
+		}
+		if expiration.IsZero() {
+			return 0
+		}
+		return expiration.Unix()
+	case selectionCriterionTeam:
+		if s.preferredTeamID != "" && certificate.TeamID == s.preferredTeamID {
+			return 1
+		}
+		return 0
+	default:
+		return 0
+	}
+}
+
+func (s codeSignGroupSelection) describeScores(scores []int64) string {
+	var descriptions []string
+	for k, criterion := range s.policy.Criteria {
+		value := fmt.Sprintf("%d", scores[k])
+		if criterion == selectionCriterionValidity && scores[k] > 0 {
+			value = time.Unix(scores[k], 0).UTC().Format(time.RFC3339)
+		}
+		descriptions = append(descriptions, fmt.Sprintf("%s=%s", criterion, value))
+	}
+	return strings.Join(descriptions, ", ")
+}
+
+func codeSignGroupKey(group export.CodeSignGroup) string {
+	var uuids []string
+	for _, profile := range group.BundleIDProfileMap() {
+		uuids = append(uuids, profile.UUID)
+	}
+	sort.Strings(uuids)
+
+	return group.Certificate().Serial + "|" + strings.Join(uuids, "|")
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+func testCodeSignGroups() []export.CodeSignGroup {
+	firstCertificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	firstCertificate.EndDate = time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	firstProfile := testProfile("Wildcard App Store", "wildcard-uuid", exportoptions.MethodAppStore, firstCertificate)
+	firstProfile.BundleID = "io.bitrise.*"
+
+	secondCertificate := testCertificate("2", "iPhone Distribution: Bitrise Bot (72SA8V3WYL)")
+	secondCertificate.EndDate = time.Date(2029, 1, 1, 0, 0, 0, 0, time.UTC)
+	secondProfile := testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, secondCertificate)
+
+	return []export.CodeSignGroup{
+		export.NewIOSGroup(firstCertificate, map[string]profileutil.ProvisioningProfileInfoModel{testBundleID: firstProfile}),
+		export.NewIOSGroup(secondCertificate, map[string]profileutil.ProvisioningProfileInfoModel{testBundleID: secondProfile}),
+	}
+}
+
+func TestCodeSignGroupSelection_selectGroup(t *testing.T) {
+	tests := []struct {
+		name                   string
+		criteria               []string
+		preferredAssets        []string
+		archiveSigningIdentity string
+		wantSerial             string
+	}{
+		{
+			name:       "tie-breaker",
+			wantSerial: "1",
+		},
+		{
+			name:       "non-wildcard",
+			criteria:   []string{selectionCriterionNonWildcard, selectionCriterionValidity},
+			wantSerial: "2",
+		},
+		{
+			name:       "validity",
+			criteria:   []string{selectionCriterionValidity, selectionCriterionNonWildcard},
+			wantSerial: "1",
+		},
+		{
+			name:                   "archive identity",
+			criteria:               []string{selectionCriterionArchiveIdentity, selectionCriterionValidity},
+			archiveSigningIdentity: "iPhone Distribution: Bitrise Bot (72SA8V3WYL)",
+			wantSerial:             "2",
+		},
+		{
+			name:            "preferred profile",
+			criteria:        []string{selectionCriterionPreferredAssets, selectionCriterionValidity},
+			preferredAssets: []string{"app-store-uuid"},
+			wantSerial:      "2",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			selection := codeSignGroupSelection{
+				policy: codeSignGroupSelectionPolicy{
+					Criteria:        tt.criteria,
+					PreferredAssets: tt.preferredAssets,
+				},
+				archiveSigningIdentity: tt.archiveSigningIdentity,
+			}
+
+			groups := testCodeSignGroups()
+			reversed := []export.CodeSignGroup{groups[1], groups[0]}
+
+			assert.Equal(t, tt.wantSerial, selection.selectGroup(groups).Certificate().Serial)
+			assert.Equal(t, tt.wantSerial, selection.selectGroup(reversed).Certificate().Serial)
+		})
+	}
+}
+
+func TestParseCodeSignGroupSelectionPolicy(t *testing.T) {
+	// When
+	policy, err := parseCodeSignGroupSelectionPolicy("archive-identity | validity", "Apple Distribution: Bitrise Bot (72SA8V3WYL)|app-store-uuid")
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, codeSignGroupSelectionPolicy{
+		Criteria:        []string{selectionCriterionArchiveIdentity, selectionCriterionValidity},
+		PreferredAssets: []string{"Apple Distribution: Bitrise Bot (72SA8V3WYL)", "app-store-uuid"},
+	}, policy)
+}
+
+func TestParseCodeSignGroupSelectionPolicy_invalid(t *testing.T) {
+	for _, criteria := range []string{"newest", "validity|validity"} {
+		_, err := parseCodeSignGroupSelectionPolicy(criteria, "")
+		assert.Error(t, err, criteria)
+	}
+}
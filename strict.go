@@ -0,0 +1,67 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/teamlapse/go-xcode/export"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+// The strict mode errors are returned instead of the warnings of the code signing heuristics, if the strict input is set.
+
+// missingProfileError is returned if a code signing group has no profile for a target.
+type missingProfileError struct {
+	BundleID string
+}
+
+func (e missingProfileError) Error() string {
+	return fmt.Sprintf("strict mode: no profile available to sign the %s target", e.BundleID)
+}
+
+// mixedSigningStyleError is returned if the selected code signing group contains both Xcode managed and manually managed profiles.
+type mixedSigningStyleError struct {
+	BundleID string
+}
+
+func (e mixedSigningStyleError) Error() string {
+	return fmt.Sprintf("strict mode: both Xcode managed and manually managed profiles in the code signing group, %s breaks the signing style of the other targets", e.BundleID)
+}
+
+// sortedBundleIDs returns the bundle IDs of the profile map in order,
+// so the strict mode errors report the same target on every run.
+func sortedBundleIDs(bundleIDProfileMap map[string]profileutil.ProvisioningProfileInfoModel) []string {
+	var bundleIDs []string
+	for bundleID := range bundleIDProfileMap {
+		bundleIDs = append(bundleIDs, bundleID)
+	}
+	sort.Strings(bundleIDs)
+	return bundleIDs
+}
+
+// multipleCodeSignGroupsError is returned if multiple code signing groups are left after filtering, instead of ranking them.
+type multipleCodeSignGroupsError struct {
+	Certificates []string
+}
+
+func (e multipleCodeSignGroupsError) Error() string {
+	return fmt.Sprintf("strict mode: multiple code signing groups found (%s), only one should be able to sign the archive", strings.Join(e.Certificates, ", "))
+}
+
+func newMultipleCodeSignGroupsError(groups []export.CodeSignGroup) multipleCodeSignGroupsError {
+	var certificates []string
+	for _, group := range groups {
+		certificates = append(certificates, fmt.Sprintf("%s (%s)", group.Certificate().CommonName, group.Certificate().Serial))
+	}
+	sort.Strings(certificates)
+	return multipleCodeSignGroupsError{Certificates: certificates}
+}
+
+// signingStyleSwitchError is returned if the archive was signed with an Xcode managed profile,
+// but it would be exported with manual code signing.
+type signingStyleSwitchError struct{}
+
+func (e signingStyleSwitchError) Error() string {
+	return "strict mode: the archive was signed with an Xcode managed profile, but the export would switch the signing style to manual"
+}
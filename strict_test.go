@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	v1log "github.com/bitrise-io/go-utils/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/certificateutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+func TestConfig_generateExportOptions_plist_strictMultipleCodeSignGroups(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	otherCertificate := testCertificate("3", "iPhone Distribution: Bitrise Bot (72SA8V3WYL)")
+	codesignAssets.certificates = append(codesignAssets.certificates, otherCertificate)
+	codesignAssets.profiles = append(codesignAssets.profiles, testProfile("Other App Store", "other-uuid", exportoptions.MethodAppStore, otherCertificate))
+	archive := testArchive(codesignAssets.profiles[1])
+
+	// When
+	_, _, lenientErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false).generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+	_, _, strictErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, true).generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+
+	// Then
+	assert.NoError(t, lenientErr)
+	assert.Equal(t, multipleCodeSignGroupsError{Certificates: []string{
+		"Apple Distribution: Bitrise Bot (72SA8V3WYL) (2)",
+		"iPhone Distribution: Bitrise Bot (72SA8V3WYL) (3)",
+	}}, strictErr)
+}
+
+func TestConfig_generateExportOptions_plist_strictSigningStyleSwitch(t *testing.T) {
+	// Given
+	codesignAssets := testCodesignAssets()
+	archive := testArchive(testProfile("XC iOS: io.bitrise.sample", "xcode-managed-uuid", exportoptions.MethodDevelopment, certificateutil.CertificateInfoModel{}))
+
+	// When
+	_, codeSigning, lenientErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false).generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+	_, _, strictErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, true).generateExportOptionsPlist("app-store", archive, testExportOptionsConfig())
+
+	// Then
+	assert.NoError(t, lenientErr)
+	assert.Equal(t, "manual", codeSigning.SigningStyle)
+	assert.Equal(t, signingStyleSwitchError{}, strictErr)
+}
+
+func TestGenerateMacExportOptionsPlist_strictMixedSigningStyle(t *testing.T) {
+	// Given
+	certificate := testCertificate("1", "Developer ID Application: Bitrise Bot (72SA8V3WYL)")
+	appProfile := testMacosProfile("XC OSX: io.bitrise.sample", "xcode-managed-uuid", exportoptions.MethodDeveloperID, certificate)
+	widgetProfile := testMacosProfile("Widget Developer ID", "widget-uuid", exportoptions.MethodDeveloperID, certificate)
+	widgetProfile.BundleID = testBundleID + ".widget"
+	codesignAssets := fakeCodesignAssetProvider{
+		certificates: []certificateutil.CertificateInfoModel{certificate},
+		profiles:     []profileutil.ProvisioningProfileInfoModel{appProfile, widgetProfile},
+	}
+
+	archive := testMacosArchive(&appProfile)
+	widget := v1xcarchive.MacosExtension{}
+	widget.InfoPlist = plistutil.PlistData{"CFBundleIdentifier": testBundleID + ".widget"}
+	widget.Entitlements = plistutil.PlistData{}
+	widget.ProvisioningProfile = &widgetProfile
+	archive.Application.Extensions = []v1xcarchive.MacosExtension{widget}
+
+	var lenientLog bytes.Buffer
+	v1log.SetOutWriter(&lenientLog)
+	defer v1log.SetOutWriter(os.Stdout)
+
+	// When
+	_, codeSigning, lenientErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, false).generateMacExportOptionsPlist("developer-id", "", 15, archive)
+	_, _, strictErr := newExportOptionsGenerator(codesignAssets, codeSignGroupSelectionPolicy{}, nil, true).generateMacExportOptionsPlist("developer-id", "", 15, archive)
+
+	// Then
+	assert.NoError(t, lenientErr)
+	assert.Contains(t, lenientLog.String(), "Both xcode managed and NON xcode managed profiles in code signing group")
+	assert.Equal(t, "automatic", codeSigning.SigningStyle)
+	assert.Equal(t, mixedSigningStyleError{BundleID: testBundleID + ".widget"}, strictErr)
+}
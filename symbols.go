@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+	v1xcarchive "github.com/teamlapse/go-xcode/xcarchive"
+)
+
+// SymbolManifest maps the binaries shipped in the archive to the dSYMs holding their debug symbols.
+type SymbolManifest struct {
+	Binaries []SymbolManifestBinary `json:"binaries"`
+	DSYMs    []ExportReportDSYM     `json:"dsyms"`
+}
+
+// SymbolManifestBinary is a single architecture slice of a binary shipped in the archive.
+type SymbolManifestBinary struct {
+	Path string `json:"path"`
+	Arch string `json:"arch"`
+	UUID string `json:"uuid"`
+	// DSYM is the path of the dSYM with the same UUID, empty if no dSYM matches the binary.
+	DSYM string `json:"dsym"`
+}
+
+// newSymbolManifest reads the UUIDs of the binaries and the dSYMs, and matches them by UUID.
+func newSymbolManifest(binaryPths, dsymPths []string) SymbolManifest {
+	var manifest SymbolManifest
+	dsymByUUID := map[string]string{}
+	for _, pth := range dsymPths {
+		uuids, err := dsymUUIDs(pth)
+		if err != nil {
+			log.Warnf("Failed to read dSYM UUIDs, error: %s", err)
+		}
+		for _, uuid := range uuids {
+			dsymByUUID[uuid.UUID] = pth
+		}
+
+		manifest.DSYMs = append(manifest.DSYMs, ExportReportDSYM{
+			Path:  pth,
+			UUIDs: uuids,
+		})
+	}
+
+	for _, pth := range binaryPths {
+		uuids, err := machoUUIDs(pth)
+		if err != nil {
+			log.Warnf("Failed to read UUIDs of (%s), error: %s", pth, err)
+			continue
+		}
+
+		for _, uuid := range uuids {
+			manifest.Binaries = append(manifest.Binaries, SymbolManifestBinary{
+				Path: pth,
+				Arch: uuid.Arch,
+				UUID: uuid.UUID,
+				DSYM: dsymByUUID[uuid.UUID],
+			})
+		}
+	}
+
+	return manifest
+}
+
+// unsymbolicated returns the binaries without a matching dSYM.
+func (manifest SymbolManifest) unsymbolicated() []SymbolManifestBinary {
+	var binaries []SymbolManifestBinary
+	for _, binary := range manifest.Binaries {
+		if binary.DSYM == "" {
+			binaries = append(binaries, binary)
+		}
+	}
+	return binaries
+}
+
+func (manifest SymbolManifest) String() (string, error) {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal symbol manifest, error: %s", err)
+	}
+	return string(data), nil
+}
+
+// iosArchiveBinaries returns the executables of the archive whose dSYMs are in the dSYM scope.
+func iosArchiveBinaries(archive xcarchive.IosArchive, scope string) []string {
+	app := archive.Application
+	apps := []v1xcarchive.IosBaseApplication{app.IosBaseApplication}
+	extensions := app.Extensions
+	if app.WatchApplication != nil {
+		apps = append(apps, app.WatchApplication.IosBaseApplication)
+		extensions = append(extensions, app.WatchApplication.Extensions...)
+	}
+	if app.ClipApplication != nil {
+		apps = append(apps, app.ClipApplication.IosBaseApplication)
+		extensions = append(extensions, app.ClipApplication.Extensions...)
+	}
+
+	var binaries []string
+	for _, app := range apps {
+		binaries = appendBundleExecutable(binaries, app.Path, app.InfoPlist)
+		if scope == dsymScopeAll {
+			binaries = append(binaries, frameworkBinaries(filepath.Join(app.Path, "Frameworks"))...)
+		}
+	}
+	if scope != dsymScopeApp {
+		for _, extension := range extensions {
+			binaries = appendBundleExecutable(binaries, extension.Path, extension.InfoPlist)
+		}
+	}
+
+	return binaries
+}
+
+// macosArchiveBinaries returns the executables of the archive whose dSYMs are in the dSYM scope.
+func macosArchiveBinaries(archive v1xcarchive.MacosArchive, scope string) []string {
+	app := archive.Application
+	binaries := appendBundleExecutable(nil, filepath.Join(app.Path, "Contents", "MacOS"), app.InfoPlist)
+	if scope == dsymScopeAll {
+		binaries = append(binaries, frameworkBinaries(filepath.Join(app.Path, "Contents", "Frameworks"))...)
+	}
+	if scope != dsymScopeApp {
+		for _, extension := range app.Extensions {
+			binaries = appendBundleExecutable(binaries, filepath.Join(extension.Path, "Contents", "MacOS"), extension.InfoPlist)
+		}
+	}
+
+	return binaries
+}
+
+func appendBundleExecutable(binaries []string, executableDir string, infoPlist plistutil.PlistData) []string {
+	executable := stringValue(infoPlist, "CFBundleExecutable")
+	if executable == "" {
+		return binaries
+	}
+	return append(binaries, filepath.Join(executableDir, executable))
+}
+
+// frameworkBinaries returns the binaries of the frameworks embedded in the frameworksDir,
+// the binary of a framework is named after the framework.
+func frameworkBinaries(frameworksDir string) []string {
+	frameworks, err := filepath.Glob(filepath.Join(frameworksDir, "*.framework"))
+	if err != nil {
+		log.Warnf("Failed to collect frameworks, error: %s", err)
+		return nil
+	}
+
+	var binaries []string
+	for _, framework := range frameworks {
+		binaries = append(binaries, filepath.Join(framework, strings.TrimSuffix(filepath.Base(framework), ".framework")))
+	}
+	return binaries
+}
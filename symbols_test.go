@@ -0,0 +1,69 @@
+package main
+
+import (
+	"debug/macho"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeThinMachO writes a 64-bit Mach-O binary with a single LC_UUID load command.
+func writeThinMachO(t *testing.T, pth string, cpu macho.Cpu, uuid [16]byte) {
+	var content []byte
+	for _, field := range []uint32{macho.Magic64, uint32(cpu), 0, uint32(macho.TypeExec), 1, 24, 0, 0} {
+		content = binary.LittleEndian.AppendUint32(content, field)
+	}
+	content = binary.LittleEndian.AppendUint32(content, uint32(lcUUID))
+	content = binary.LittleEndian.AppendUint32(content, 24)
+	content = append(content, uuid[:]...)
+
+	assert.NoError(t, os.MkdirAll(filepath.Dir(pth), 0755))
+	assert.NoError(t, os.WriteFile(pth, content, 0644))
+}
+
+func TestNewSymbolManifest(t *testing.T) {
+	// Given
+	dir := t.TempDir()
+	appUUID := [16]byte{0xA1, 0xB2, 0xC3, 0xD4, 0xE5, 0xF6, 0x07, 0x18, 0x29, 0x3A, 0x4B, 0x5C, 0x6D, 0x7E, 0x8F, 0x90}
+	widgetUUID := [16]byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0A, 0x0B, 0x0C, 0x0D, 0x0E, 0x0F, 0x10}
+	staleWidgetUUID := [16]byte{0xFF}
+
+	appBinary := filepath.Join(dir, "Products/Applications/Sample.app/Sample")
+	widgetBinary := filepath.Join(dir, "Products/Applications/Sample.app/PlugIns/Widget.appex/Widget")
+	writeThinMachO(t, appBinary, macho.CpuArm64, appUUID)
+	writeThinMachO(t, widgetBinary, macho.CpuArm64, widgetUUID)
+
+	appDSYM := filepath.Join(dir, "dSYMs/Sample.app.dSYM")
+	widgetDSYM := filepath.Join(dir, "dSYMs/Widget.appex.dSYM")
+	writeThinMachO(t, filepath.Join(appDSYM, "Contents/Resources/DWARF/Sample"), macho.CpuArm64, appUUID)
+	writeThinMachO(t, filepath.Join(widgetDSYM, "Contents/Resources/DWARF/Widget"), macho.CpuArm64, staleWidgetUUID)
+
+	// When
+	manifest := newSymbolManifest([]string{appBinary, widgetBinary}, []string{appDSYM, widgetDSYM})
+
+	// Then
+	assert.Equal(t, []SymbolManifestBinary{
+		{Path: appBinary, Arch: "arm64", UUID: "A1B2C3D4-E5F6-0718-293A-4B5C6D7E8F90", DSYM: appDSYM},
+		{Path: widgetBinary, Arch: "arm64", UUID: "01020304-0506-0708-090A-0B0C0D0E0F10"},
+	}, manifest.Binaries)
+	assert.Equal(t, []ExportReportDSYM{
+		{Path: appDSYM, UUIDs: []BinaryUUID{{Arch: "arm64", UUID: "A1B2C3D4-E5F6-0718-293A-4B5C6D7E8F90"}}},
+		{Path: widgetDSYM, UUIDs: []BinaryUUID{{Arch: "arm64", UUID: "FF000000-0000-0000-0000-000000000000"}}},
+	}, manifest.DSYMs)
+	assert.Equal(t, []SymbolManifestBinary{manifest.Binaries[1]}, manifest.unsymbolicated())
+}
+
+func TestFrameworkBinaries(t *testing.T) {
+	// Given
+	frameworksDir := filepath.Join(t.TempDir(), "Frameworks")
+	assert.NoError(t, os.MkdirAll(filepath.Join(frameworksDir, "Alamofire.framework"), 0755))
+
+	// When
+	binaries := frameworkBinaries(frameworksDir)
+
+	// Then
+	assert.Equal(t, []string{filepath.Join(frameworksDir, "Alamofire.framework", "Alamofire")}, binaries)
+}
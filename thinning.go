@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
+	"github.com/teamlapse/go-xcode/exportoptions"
+)
+
+// Values of the thinning export option, besides a device model identifier
+const (
+	thinningNone        = "<none>"
+	thinningAllVariants = "<thin-for-all-variants>"
+)
+
+const (
+	thinningSizeReportFileName = "App Thinning Size Report.txt"
+	// thinnedVariantsDirName is the directory of the export dir containing the IPAs of the thinned variants.
+	thinnedVariantsDirName = "Apps"
+)
+
+var (
+	deviceModelIdentifierPattern = regexp.MustCompile(`^[A-Za-z]+[0-9]+,[0-9]+$`)
+	sizePattern                  = regexp.MustCompile(`^(Zero|[0-9]+(?:\.[0-9]+)?) ?(bytes|byte|KB|MB|GB)$`)
+	variantDevicePattern         = regexp.MustCompile(`device: ([^\s\]]+?),?(?:\s|\])`)
+	variantAppSizePattern        = regexp.MustCompile(`^App size: (.+) compressed, (.+) uncompressed$`)
+)
+
+// sizeUnits are the decimal units of the sizes in the App Thinning Size Report.
+var sizeUnits = map[string]int64{
+	"byte":  1,
+	"bytes": 1,
+	"KB":    1000,
+	"MB":    1000 * 1000,
+	"GB":    1000 * 1000 * 1000,
+}
+
+// thinningConfig configures the App Thinning of the non-App Store exports.
+type thinningConfig struct {
+	// Thinning is the value of the thinning export option, empty if the IPAs are not thinned.
+	Thinning string
+	// SizeBudget is the maximum compressed size of a thinned variant in bytes, 0 if the size is not checked.
+	SizeBudget int64
+}
+
+func (c thinningConfig) isEnabled() bool {
+	return c.Thinning != ""
+}
+
+// parseThinningConfig validates the thinning (none, all or a device model identifier) and the size budget (for example: 200 MB).
+func parseThinningConfig(thinning, sizeBudget string) (thinningConfig, error) {
+	var config thinningConfig
+
+	switch thinning = strings.TrimSpace(thinning); thinning {
+	case "", "none", thinningNone:
+	case "all", thinningAllVariants:
+		config.Thinning = thinningAllVariants
+	default:
+		if !deviceModelIdentifierPattern.MatchString(thinning) {
+			return thinningConfig{}, fmt.Errorf("invalid thinning (%s), use none, all or a device model identifier, for example: iPhone15,2", thinning)
+		}
+		config.Thinning = thinning
+	}
+
+	if sizeBudget = strings.TrimSpace(sizeBudget); sizeBudget != "" {
+		if !config.isEnabled() {
+			return thinningConfig{}, fmt.Errorf("size budget is specified without thinning")
+		}
+
+		budget, err := parseSize(sizeBudget)
+		if err != nil {
+			return thinningConfig{}, fmt.Errorf("invalid size budget, error: %s", err)
+		}
+		config.SizeBudget = budget
+	}
+
+	return config, nil
+}
+
+// validateProvidedThinning returns an error if the thinning input would be silently ignored:
+// the export options content replaces the generated export options, but it does not set the thinning key.
+func validateProvidedThinning(config thinningConfig, exportOptionsMode string, providedOptions map[string]interface{}) error {
+	if !config.isEnabled() || exportOptionsMode == exportOptionsModeMerge {
+		return nil
+	}
+	if _, ok := providedOptions[exportoptions.ThinningKey]; ok {
+		return nil
+	}
+	return fmt.Errorf("App Thinning is set, but the export options content replaces the generated export options without the %s key, set the key or use the merge export options mode", exportoptions.ThinningKey)
+}
+
+// isThinningMethod returns true if the IPAs exported with the distribution method can be thinned.
+func isThinningMethod(distributionMethod string) bool {
+	method, err := parseExportMethod(distributionMethod)
+	if err != nil {
+		return false
+	}
+	return method != exportoptions.MethodAppStore
+}
+
+// parseSize parses a size of the App Thinning Size Report, for example: 6.7 MB or Zero KB.
+func parseSize(size string) (int64, error) {
+	match := sizePattern.FindStringSubmatch(strings.TrimSpace(size))
+	if match == nil {
+		return 0, fmt.Errorf("invalid size (%s), for example: 200 MB", size)
+	}
+	if match[1] == "Zero" {
+		return 0, nil
+	}
+
+	value, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid size (%s), error: %s", size, err)
+	}
+	return int64(value * float64(sizeUnits[match[2]])), nil
+}
+
+// ThinnedVariant is a variant of the App Thinning Size Report.
+type ThinnedVariant struct {
+	// Variant is the file name of the variant's IPA.
+	Variant string `json:"variant"`
+	// Devices are the device model identifiers the variant supports, empty for the universal variant.
+	Devices          []string `json:"devices"`
+	Universal        bool     `json:"universal"`
+	CompressedSize   int64    `json:"compressed_size"`
+	UncompressedSize int64    `json:"uncompressed_size"`
+}
+
+// ThinningSizeReport is the parsed App Thinning Size Report of an export.
+type ThinningSizeReport struct {
+	DistributionMethod string           `json:"distribution_method"`
+	Variants           []ThinnedVariant `json:"variants"`
+}
+
+// parseThinningSizeReport parses the variants of the App Thinning Size Report.
+func parseThinningSizeReport(content string) ([]ThinnedVariant, error) {
+	var variants []ThinnedVariant
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+
+		if strings.HasPrefix(line, "Variant:") {
+			variants = append(variants, ThinnedVariant{
+				Variant: strings.TrimSpace(strings.TrimPrefix(line, "Variant:")),
+				Devices: []string{},
+			})
+			continue
+		}
+		if len(variants) == 0 {
+			continue
+		}
+		variant := &variants[len(variants)-1]
+
+		if strings.HasPrefix(line, "Supported variant descriptors:") {
+			descriptors := strings.TrimSpace(strings.TrimPrefix(line, "Supported variant descriptors:"))
+			variant.Universal = descriptors == "Universal"
+			for _, match := range variantDevicePattern.FindAllStringSubmatch(descriptors, -1) {
+				if !sliceutil.IsStringInSlice(match[1], variant.Devices) {
+					variant.Devices = append(variant.Devices, match[1])
+				}
+			}
+		} else if match := variantAppSizePattern.FindStringSubmatch(line); match != nil {
+			compressed, err := parseSize(match[1])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the size of the %s variant, error: %s", variant.Variant, err)
+			}
+			uncompressed, err := parseSize(match[2])
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse the size of the %s variant, error: %s", variant.Variant, err)
+			}
+			variant.CompressedSize = compressed
+			variant.UncompressedSize = uncompressed
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read the App Thinning Size Report, error: %s", err)
+	}
+
+	return variants, nil
+}
+
+// readThinningSizeReport parses the App Thinning Size Report of the export dir, it returns nil if xcodebuild did not write the report.
+func readThinningSizeReport(exportDir string) ([]ThinnedVariant, error) {
+	content, err := os.ReadFile(filepath.Join(exportDir, thinningSizeReportFileName))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read %s, error: %s", thinningSizeReportFileName, err)
+	}
+
+	return parseThinningSizeReport(string(content))
+}
+
+// exportedIPAPaths returns the IPAs of the export.
+// If the IPAs are thinned, xcodebuild writes them to the thinned variants directory:
+// the universal variants are returned then, or every variant if the IPAs are thinned for a single device.
+func exportedIPAPaths(export MethodExport) ([]string, error) {
+	ipas, err := filepath.Glob(filepath.Join(export.ExportDir, "*.ipa"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect ipa files, error: %s", err)
+	}
+	if len(ipas) > 0 || len(export.ThinnedVariants) == 0 {
+		return ipas, nil
+	}
+
+	var universalIPAs, variantIPAs []string
+	for _, variant := range export.ThinnedVariants {
+		pth := filepath.Join(export.ExportDir, thinnedVariantsDirName, variant.Variant)
+		if variant.Universal {
+			universalIPAs = append(universalIPAs, pth)
+		}
+		variantIPAs = append(variantIPAs, pth)
+	}
+	if len(universalIPAs) > 0 {
+		return universalIPAs, nil
+	}
+	return variantIPAs, nil
+}
+
+// exportThinnedVariants deploys the thinned variants directory of the export as a zip.
+func (s Step) exportThinnedVariants(export MethodExport, deployDir string, fileNames outputFileNames) (string, error) {
+	variantsDir := filepath.Join(export.ExportDir, thinnedVariantsDirName)
+	if exist, err := pathutil.IsDirExists(variantsDir); err != nil {
+		return "", fmt.Errorf("failed to check if the thinned variants directory exists, error: %s", err)
+	} else if !exist {
+		s.logger.Warnf("No thinned variants directory found in the %s export", export.DistributionMethod)
+		return "", nil
+	}
+
+	zipPath := filepath.Join(deployDir, fileNames.methodFileName("app_thinning_variants", ".app_thinning_variants", ".zip", export.DistributionMethod))
+	envKey := s.outputKey(distributionMethodEnvKey(bitriseAppThinningVariantsPthEnvKey, export.DistributionMethod))
+	if err := s.zipAndExportOutput([]string{variantsDir}, zipPath, envKey); err != nil {
+		return "", fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The %s thinned variants zip path is now available in the Environment Variable: %s (value: %s)", export.DistributionMethod, envKey, zipPath)
+
+	return zipPath, nil
+}
+
+// exportThinningSizeReports exports the JSON of the parsed App Thinning Size Reports.
+func (s Step) exportThinningSizeReports(deployDir string, reports []ThinningSizeReport) error {
+	for _, report := range reports {
+		s.logger.Printf("%s thinned variants:", report.DistributionMethod)
+		for _, variant := range report.Variants {
+			devices := strings.Join(variant.Devices, ", ")
+			if variant.Universal {
+				devices = "universal"
+			}
+			s.logger.Printf("- %s (%s): %s compressed, %s uncompressed", variant.Variant, devices, formatSize(variant.CompressedSize), formatSize(variant.UncompressedSize))
+		}
+	}
+
+	content, err := json.MarshalIndent(reports, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal App Thinning size report, error: %s", err)
+	}
+
+	reportPath := filepath.Join(deployDir, "app_thinning_size_report.json")
+	envKey := s.outputKey(bitriseAppThinningSizeReportPthEnvKey)
+	if err := s.exportOutputFileContent(string(content), reportPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The App Thinning size report path is now available in the Environment Variable: %s (value: %s)", envKey, reportPath)
+
+	return nil
+}
+
+// verifyThinningSizeBudget fails if the compressed size of a thinned variant exceeds the size budget.
+func (s Step) verifyThinningSizeBudget(reports []ThinningSizeReport, sizeBudget int64) error {
+	if sizeBudget == 0 {
+		return nil
+	}
+
+	var exceeding []string
+	for _, report := range reports {
+		for _, variant := range report.Variants {
+			if variant.CompressedSize > sizeBudget {
+				exceeding = append(exceeding, fmt.Sprintf("%s (%s, %s compressed)", variant.Variant, report.DistributionMethod, formatSize(variant.CompressedSize)))
+			}
+		}
+	}
+	if len(exceeding) == 0 {
+		return nil
+	}
+
+	s.logger.Warnf("%d thinned variants exceed the size budget (%s):", len(exceeding), formatSize(sizeBudget))
+	for _, variant := range exceeding {
+		s.logger.Warnf("- %s", variant)
+	}
+
+	return fmt.Errorf("%d thinned variants exceed the size budget (%s)", len(exceeding), formatSize(sizeBudget))
+}
+
+func formatSize(size int64) string {
+	for _, unit := range []string{"GB", "MB", "KB"} {
+		if size >= sizeUnits[unit] {
+			return strconv.FormatFloat(float64(size)/float64(sizeUnits[unit]), 'f', 1, 64) + " " + unit
+		}
+	}
+	return fmt.Sprintf("%d bytes", size)
+}
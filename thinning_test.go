@@ -0,0 +1,125 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const testThinningSizeReport = `
+App Thinning Size Report for All Variants of Sample
+
+Variant: Sample-1A2B3C4D.ipa
+Supported variant descriptors: [device: iPhone14,2, os-version: 16.0], [device: iPhone14,3, os-version: 16.0], [device: iPhone14,2, os-version: 17.0]
+App + On Demand Resources size: 12.4 MB compressed, 31.2 MB uncompressed
+App size: 12.4 MB compressed, 31.2 MB uncompressed
+On Demand Resources size: Zero KB compressed, Zero KB uncompressed
+
+Variant: Sample.ipa
+Supported variant descriptors: Universal
+App + On Demand Resources size: 18.5 MB compressed, 46 MB uncompressed
+App size: 18.5 MB compressed, 46 MB uncompressed
+On Demand Resources size: Zero KB compressed, Zero KB uncompressed
+`
+
+func TestParseThinningConfig(t *testing.T) {
+	config, err := parseThinningConfig("all", "15 MB")
+	assert.NoError(t, err)
+	assert.Equal(t, thinningConfig{Thinning: thinningAllVariants, SizeBudget: 15000000}, config)
+
+	config, err = parseThinningConfig(" iPhone15,2 ", "")
+	assert.NoError(t, err)
+	assert.Equal(t, thinningConfig{Thinning: "iPhone15,2"}, config)
+
+	config, err = parseThinningConfig("none", "")
+	assert.NoError(t, err)
+	assert.False(t, config.isEnabled())
+
+	for _, inputs := range [][]string{
+		{"iPhone", ""},
+		{"none", "15 MB"},
+		{"all", "15 megabytes"},
+	} {
+		_, err := parseThinningConfig(inputs[0], inputs[1])
+		assert.Error(t, err, inputs)
+	}
+}
+
+func TestValidateProvidedThinning(t *testing.T) {
+	config := thinningConfig{Thinning: thinningAllVariants}
+
+	assert.Error(t, validateProvidedThinning(config, exportOptionsModeReplace, map[string]interface{}{"method": "ad-hoc"}))
+	assert.NoError(t, validateProvidedThinning(config, exportOptionsModeReplace, map[string]interface{}{"thinning": thinningAllVariants}))
+	assert.NoError(t, validateProvidedThinning(config, exportOptionsModeMerge, map[string]interface{}{"method": "ad-hoc"}))
+	assert.NoError(t, validateProvidedThinning(thinningConfig{}, exportOptionsModeReplace, map[string]interface{}{"method": "ad-hoc"}))
+}
+
+func TestGenerateExportOptionsPlist_thinning(t *testing.T) {
+	// Given
+	generator, archive := testExportOptionsGenerator(testTeamID)
+	config := testExportOptionsConfig()
+	config.TeamID = testTeamID
+	config.Thinning = thinningAllVariants
+
+	// When
+	result, _, err := generator.generateExportOptionsPlist("development", archive, config)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Contains(t, result, "<key>thinning</key>")
+	assert.Contains(t, result, "<string>&lt;thin-for-all-variants&gt;</string>")
+}
+
+func TestParseThinningSizeReport(t *testing.T) {
+	// When
+	variants, err := parseThinningSizeReport(testThinningSizeReport)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []ThinnedVariant{
+		{
+			Variant:          "Sample-1A2B3C4D.ipa",
+			Devices:          []string{"iPhone14,2", "iPhone14,3"},
+			CompressedSize:   12400000,
+			UncompressedSize: 31200000,
+		},
+		{
+			Variant:          "Sample.ipa",
+			Devices:          []string{},
+			Universal:        true,
+			CompressedSize:   18500000,
+			UncompressedSize: 46000000,
+		},
+	}, variants)
+}
+
+func TestExportedIPAPaths(t *testing.T) {
+	// Given
+	exportDir := t.TempDir()
+	variantsDir := filepath.Join(exportDir, thinnedVariantsDirName)
+	assert.NoError(t, os.MkdirAll(variantsDir, 0755))
+	variants, err := parseThinningSizeReport(testThinningSizeReport)
+	assert.NoError(t, err)
+
+	// When
+	ipas, err := exportedIPAPaths(MethodExport{ExportDir: exportDir, ThinnedVariants: variants})
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []string{filepath.Join(variantsDir, "Sample.ipa")}, ipas)
+}
+
+func TestVerifyThinningSizeBudget(t *testing.T) {
+	// Given
+	step := Step{logger: &recordingLogger{}}
+	variants, err := parseThinningSizeReport(testThinningSizeReport)
+	assert.NoError(t, err)
+	reports := []ThinningSizeReport{{DistributionMethod: "ad-hoc", Variants: variants}}
+
+	// Then
+	assert.NoError(t, step.verifyThinningSizeBudget(reports, 0))
+	assert.NoError(t, step.verifyThinningSizeBudget(reports, 20000000))
+	assert.EqualError(t, step.verifyThinningSizeBudget(reports, 15000000), "1 thinned variants exceed the size budget (15.0 MB)")
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/v2/retryhttp"
+	"github.com/bitrise-io/go-utils/ziputil"
+	"github.com/hashicorp/go-retryablehttp"
+)
+
+// dSYM upload targets
+const (
+	dsymUploadTargetSentry    = "sentry"
+	dsymUploadTargetMultipart = "multipart"
+)
+
+// dSYM upload contents
+const (
+	dsymUploadContentZip  = "zip"
+	dsymUploadContentDSYM = "dsym"
+)
+
+// dsymUploadFormField is the multipart form field of the uploaded file, the Sentry debug files endpoint expects this name.
+const dsymUploadFormField = "file"
+
+// dsymUploadConfig configures the upload of the dSYMs to a crash reporting service.
+type dsymUploadConfig struct {
+	URL        string
+	Target     string
+	Content    string
+	HeaderName string
+	// HeaderValue is sensitive, it is never logged.
+	HeaderValue string
+	RetryMax    int
+}
+
+func (c dsymUploadConfig) isEnabled() bool {
+	return c.URL != ""
+}
+
+// parseDSYMUploadConfig validates the dSYM upload inputs, authHeader is expected in the `Name: Value` format.
+func parseDSYMUploadConfig(uploadURL, target, content, authHeader string, retryMax int) (dsymUploadConfig, error) {
+	config := dsymUploadConfig{
+		URL:      strings.TrimSpace(uploadURL),
+		Target:   target,
+		Content:  content,
+		RetryMax: retryMax,
+	}
+	if !config.isEnabled() {
+		return config, nil
+	}
+
+	parsed, err := url.Parse(config.URL)
+	if err != nil {
+		// The parse error contains the full URL, only its cause is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return dsymUploadConfig{}, fmt.Errorf("invalid dSYM upload URL, error: %s", err)
+	}
+	if (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return dsymUploadConfig{}, fmt.Errorf("invalid dSYM upload URL (%s), an absolute http(s) URL is required", redactArchiveURL(config.URL))
+	}
+	if target == dsymUploadTargetSentry && !strings.Contains(parsed.Path, "/files/dsyms") {
+		return dsymUploadConfig{}, fmt.Errorf("invalid Sentry dSYM upload URL (%s), the debug files endpoint is expected: https://sentry.io/api/0/projects/<organization>/<project>/files/dsyms/", redactArchiveURL(config.URL))
+	}
+
+	if authHeader = strings.TrimSpace(authHeader); authHeader != "" {
+		name, value, found := strings.Cut(authHeader, ":")
+		if !found || strings.TrimSpace(name) == "" {
+			return dsymUploadConfig{}, fmt.Errorf("invalid dSYM upload auth header, the `Name: Value` format is expected")
+		}
+		config.HeaderName = strings.TrimSpace(name)
+		config.HeaderValue = strings.TrimSpace(value)
+	}
+
+	return config, nil
+}
+
+// DSYMUploadResult is the upload result of a single file.
+type DSYMUploadResult struct {
+	Path       string `json:"path"`
+	StatusCode int    `json:"status_code,omitempty"`
+	// DebugIDs are the IDs of the debug files processed by Sentry.
+	DebugIDs []string `json:"debug_ids,omitempty"`
+	Error    string   `json:"error,omitempty"`
+}
+
+func (r DSYMUploadResult) succeeded() bool {
+	return r.Error == ""
+}
+
+type dsymUploader struct {
+	config dsymUploadConfig
+	client *retryablehttp.Client
+}
+
+// newDSYMUploader returns an uploader which reports the last response once the retries are exhausted,
+// so that a persisting 5xx is reported with its status code instead of a generic error.
+func newDSYMUploader(config dsymUploadConfig, client *retryablehttp.Client) dsymUploader {
+	client.ErrorHandler = lastResponseErrorHandler
+	return dsymUploader{config: config, client: client}
+}
+
+func lastResponseErrorHandler(resp *http.Response, err error, _ int) (*http.Response, error) {
+	if resp != nil {
+		return resp, nil
+	}
+	return nil, err
+}
+
+// upload sends the file in a multipart POST request, the request is retried by the client on connection errors and 5xx responses.
+func (u dsymUploader) upload(pth string) DSYMUploadResult {
+	result := DSYMUploadResult{Path: pth}
+
+	body, contentType := multipartFileBody(pth)
+	req, err := retryablehttp.NewRequest(http.MethodPost, u.config.URL, body)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to create request: %s", err)
+		return result
+	}
+	req.Header.Set("Content-Type", contentType)
+	if u.config.HeaderName != "" {
+		req.Header.Set(u.config.HeaderName, u.config.HeaderValue)
+	}
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		// The errors of the HTTP client contain the full URL, only their cause is reported.
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		result.Error = fmt.Sprintf("request to %s failed: %s", redactArchiveURL(u.config.URL), err)
+		return result
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			log.Warnf("Failed to close response body, error: %s", err)
+		}
+	}()
+
+	result.StatusCode = resp.StatusCode
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to read response: %s", err)
+		return result
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		result.Error = fmt.Sprintf("unexpected status code: %d, response: %s", resp.StatusCode, strings.TrimSpace(string(respBody)))
+		return result
+	}
+
+	if u.config.Target == dsymUploadTargetSentry {
+		var debugFiles []struct {
+			DebugID string `json:"debugId"`
+			UUID    string `json:"uuid"`
+		}
+		if err := json.Unmarshal(respBody, &debugFiles); err != nil {
+			log.Warnf("Failed to parse Sentry response, error: %s", err)
+		}
+		for _, debugFile := range debugFiles {
+			if debugFile.DebugID != "" {
+				result.DebugIDs = append(result.DebugIDs, debugFile.DebugID)
+			} else if debugFile.UUID != "" {
+				result.DebugIDs = append(result.DebugIDs, debugFile.UUID)
+			}
+		}
+	}
+
+	return result
+}
+
+// multipartFileBody streams the file in a multipart form, the body is reopened for every retry.
+func multipartFileBody(pth string) (retryablehttp.ReaderFunc, string) {
+	boundary := multipart.NewWriter(io.Discard).Boundary()
+	contentType := "multipart/form-data; boundary=" + boundary
+
+	return func() (io.Reader, error) {
+		file, err := os.Open(pth)
+		if err != nil {
+			return nil, err
+		}
+
+		pr, pw := io.Pipe()
+		go func() {
+			defer func() {
+				if err := file.Close(); err != nil {
+					log.Warnf("Failed to close file (%s), error: %s", pth, err)
+				}
+			}()
+
+			writer := multipart.NewWriter(pw)
+			if err := writer.SetBoundary(boundary); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			part, err := writer.CreateFormFile(dsymUploadFormField, filepath.Base(pth))
+			if err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			if _, err := io.Copy(part, file); err != nil {
+				pw.CloseWithError(err)
+				return
+			}
+			pw.CloseWithError(writer.Close())
+		}()
+
+		return pr, nil
+	}, contentType
+}
+
+// dsymUploadFiles returns the files to upload: the dSYM zips, or every dSYM zipped separately into the tmpDir.
+func dsymUploadFiles(content string, dsymZipPths, dsymPths []string, tmpDir string) ([]string, error) {
+	if content == dsymUploadContentZip {
+		return dsymZipPths, nil
+	}
+
+	var pths []string
+	for _, dsym := range dsymPths {
+		zipPth := filepath.Join(tmpDir, filepath.Base(dsym)+".zip")
+		if err := ziputil.ZipDir(dsym, zipPth, false); err != nil {
+			return nil, fmt.Errorf("failed to zip %s, error: %s", filepath.Base(dsym), err)
+		}
+		pths = append(pths, zipPth)
+	}
+	return pths, nil
+}
+
+// uploadDSYMs uploads the dSYMs of the archive, and exports the upload results.
+func (s Step) uploadDSYMs(opts ExportOpts, dsymZipPths []string) error {
+	s.logger.Println()
+	s.logger.Infof("Uploading dSYMs to %s", redactArchiveURL(opts.DSYMUpload.URL))
+
+	tmpDir, err := pathutil.NormalizedOSTempDirPath("__dsym_upload__")
+	if err != nil {
+		return fmt.Errorf("failed to create tmp dir, error: %s", err)
+	}
+	defer func() {
+		if err := os.RemoveAll(tmpDir); err != nil {
+			s.logger.Warnf("Failed to remove temporary dSYM upload dir (%s), error: %s", tmpDir, err)
+		}
+	}()
+
+	pths, err := dsymUploadFiles(opts.DSYMUpload.Content, dsymZipPths, opts.DSYMs.dSYMs(), tmpDir)
+	if err != nil {
+		return fmt.Errorf("failed to prepare dSYM upload, error: %s", err)
+	}
+	if len(pths) == 0 {
+		s.logger.Warnf("No dSYM to upload")
+		return nil
+	}
+
+	client := retryhttp.NewClient(s.logger)
+	client.RetryMax = opts.DSYMUpload.RetryMax
+	uploader := newDSYMUploader(opts.DSYMUpload, client)
+
+	var results []DSYMUploadResult
+	var failed []string
+	for _, pth := range pths {
+		result := uploader.upload(pth)
+		if result.succeeded() {
+			s.logger.Printf("- %s: uploaded (%d)", filepath.Base(pth), result.StatusCode)
+		} else {
+			s.logger.Errorf("- %s: %s", filepath.Base(pth), result.Error)
+			failed = append(failed, filepath.Base(pth))
+		}
+		results = append(results, result)
+	}
+
+	status := "succeeded"
+	if len(failed) > 0 {
+		status = "failed"
+	}
+	statusEnvKey := s.outputKey(bitriseDSYMUploadStatusEnvKey)
+	if err := s.exportEnv(statusEnvKey, status); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", statusEnvKey, err)
+	}
+
+	resultsContent, err := json.MarshalIndent(results, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal dSYM upload results, error: %s", err)
+	}
+	resultsPath := filepath.Join(opts.DeployDir, "dsym_upload_results.json")
+	envKey := s.outputKey(bitriseDSYMUploadResultsPthEnvKey)
+	if err := s.exportOutputFileContent(string(resultsContent), resultsPath, envKey); err != nil {
+		return fmt.Errorf("failed to export %s, error: %s", envKey, err)
+	}
+
+	s.logger.Donef("The dSYM upload results path is now available in the Environment Variable: %s (value: %s)", envKey, resultsPath)
+
+	if len(failed) > 0 {
+		return fmt.Errorf("failed to upload dSYMs: %s", strings.Join(failed, ", "))
+	}
+
+	return nil
+}
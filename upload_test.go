@@ -0,0 +1,149 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/go-retryablehttp"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDSYMUploadConfig(t *testing.T) {
+	config, err := parseDSYMUploadConfig(" https://sentry.io/api/0/projects/acme/ios/files/dsyms/ ", dsymUploadTargetSentry, dsymUploadContentZip, "Authorization: Bearer token:with:colons", 3)
+	assert.NoError(t, err)
+	assert.Equal(t, dsymUploadConfig{
+		URL:         "https://sentry.io/api/0/projects/acme/ios/files/dsyms/",
+		Target:      dsymUploadTargetSentry,
+		Content:     dsymUploadContentZip,
+		HeaderName:  "Authorization",
+		HeaderValue: "Bearer token:with:colons",
+		RetryMax:    3,
+	}, config)
+
+	config, err = parseDSYMUploadConfig("", dsymUploadTargetSentry, dsymUploadContentZip, "", 3)
+	assert.NoError(t, err)
+	assert.False(t, config.isEnabled())
+
+	for _, tt := range []struct {
+		url        string
+		target     string
+		authHeader string
+	}{
+		{url: "symbols.example.com/upload", target: dsymUploadTargetMultipart},
+		{url: "https://symbols.example.com/upload", target: dsymUploadTargetSentry},
+		{url: "https://symbols.example.com/upload", target: dsymUploadTargetMultipart, authHeader: "Bearer token"},
+	} {
+		_, err := parseDSYMUploadConfig(tt.url, tt.target, dsymUploadContentZip, tt.authHeader, 3)
+		assert.Error(t, err, tt.url)
+	}
+}
+
+func TestDSYMUploaderUpload(t *testing.T) {
+	// Given
+	zipPth := filepath.Join(t.TempDir(), "Sample.dSYM.zip")
+	assert.NoError(t, os.WriteFile(zipPth, []byte("dsym"), 0644))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if requests == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		file, header, err := r.FormFile(dsymUploadFormField)
+		assert.NoError(t, err)
+		assert.Equal(t, "Sample.dSYM.zip", header.Filename)
+		content, err := io.ReadAll(file)
+		assert.NoError(t, err)
+		assert.Equal(t, "dsym", string(content))
+
+		w.WriteHeader(http.StatusCreated)
+		_, err = w.Write([]byte(`[{"debugId":"a1b2c3d4-e5f6-0718-293a-4b5c6d7e8f90","objectName":"Sample"}]`))
+		assert.NoError(t, err)
+	}))
+	defer server.Close()
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+	client.RetryMax = 1
+	uploader := dsymUploader{
+		config: dsymUploadConfig{
+			URL:         server.URL + "/api/0/projects/acme/ios/files/dsyms/",
+			Target:      dsymUploadTargetSentry,
+			HeaderName:  "Authorization",
+			HeaderValue: "Bearer token",
+		},
+		client: client,
+	}
+
+	// When
+	result := uploader.upload(zipPth)
+
+	// Then
+	assert.True(t, result.succeeded(), result.Error)
+	assert.Equal(t, 2, requests)
+	assert.Equal(t, DSYMUploadResult{
+		Path:       zipPth,
+		StatusCode: http.StatusCreated,
+		DebugIDs:   []string{"a1b2c3d4-e5f6-0718-293a-4b5c6d7e8f90"},
+	}, result)
+}
+
+func TestDSYMUploaderUpload_serverError(t *testing.T) {
+	// Given
+	zipPth := filepath.Join(t.TempDir(), "Sample.dSYM.zip")
+	assert.NoError(t, os.WriteFile(zipPth, []byte("dsym"), 0600))
+
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryWaitMin = time.Millisecond
+	client.RetryWaitMax = time.Millisecond
+	client.RetryMax = 2
+	uploader := newDSYMUploader(dsymUploadConfig{URL: server.URL + "/dsyms?token=secret", Target: dsymUploadTargetMultipart}, client)
+
+	// When
+	result := uploader.upload(zipPth)
+
+	// Then
+	assert.Equal(t, 3, requests)
+	assert.Equal(t, http.StatusServiceUnavailable, result.StatusCode)
+	assert.Equal(t, "unexpected status code: 503, response: ", result.Error)
+}
+
+func TestDSYMUploaderUpload_connectionError(t *testing.T) {
+	// Given
+	zipPth := filepath.Join(t.TempDir(), "Sample.dSYM.zip")
+	assert.NoError(t, os.WriteFile(zipPth, []byte("dsym"), 0600))
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	serverURL := server.URL
+	server.Close()
+
+	client := retryablehttp.NewClient()
+	client.Logger = nil
+	client.RetryMax = 0
+	uploader := newDSYMUploader(dsymUploadConfig{URL: serverURL + "/dsyms?token=secret", Target: dsymUploadTargetMultipart}, client)
+
+	// When
+	result := uploader.upload(zipPth)
+
+	// Then
+	assert.Contains(t, result.Error, "request to "+serverURL+"/dsyms failed: ")
+	assert.NotContains(t, result.Error, "secret")
+}
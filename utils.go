@@ -12,9 +12,11 @@ import (
 
 	"github.com/bitrise-io/go-utils/log"
 	"github.com/bitrise-io/go-utils/pathutil"
+	"github.com/bitrise-io/go-utils/sliceutil"
 	"github.com/teamlapse/go-xcode/certificateutil"
 	"github.com/teamlapse/go-xcode/export"
 	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/models"
 	"github.com/teamlapse/go-xcode/profileutil"
 	"github.com/teamlapse/go-xcode/v2/xcarchive"
 )
@@ -41,6 +43,62 @@ func ParseExportProduct(product string) (ExportProduct, error) {
 	}
 }
 
+// parseDistributionMethods parses the pipe separated list of distribution methods,
+// macOS archives support a different set of methods than iOS archives.
+func parseDistributionMethods(list string, isMacOS bool) ([]string, error) {
+	supportedMethods := []string{"development", "app-store", "ad-hoc", "enterprise", exportMethodDebugging, exportMethodAppStoreConnect, exportMethodReleaseTesting}
+	if isMacOS {
+		supportedMethods = []string{"development", "app-store", "developer-id", "package", exportMethodDebugging, exportMethodAppStoreConnect}
+	}
+
+	var methods []string
+	parsedMethods := map[exportoptions.Method]string{}
+	for _, item := range strings.Split(list, "|") {
+		method := strings.TrimSpace(item)
+		if method == "" {
+			continue
+		}
+
+		if !sliceutil.IsStringInSlice(method, supportedMethods) {
+			return nil, fmt.Errorf("unkown method (%s), supported methods: %s", method, strings.Join(supportedMethods, ", "))
+		}
+
+		parsedMethod, err := parseExportMethod(method)
+		if err != nil {
+			return nil, err
+		}
+		if previous, ok := parsedMethods[parsedMethod]; ok {
+			if previous == method {
+				return nil, fmt.Errorf("method (%s) is specified more than once", method)
+			}
+			return nil, fmt.Errorf("method (%s) is specified more than once, as %s and %s", parsedMethod, previous, method)
+		}
+		parsedMethods[parsedMethod] = method
+		methods = append(methods, method)
+	}
+
+	if len(methods) == 0 {
+		return nil, fmt.Errorf("no method specified")
+	}
+
+	return methods, nil
+}
+
+// distributionMethodEnvKey returns the per distribution method variant of an output env key,
+// for example: BITRISE_IPA_PATH -> BITRISE_IPA_PATH_AD_HOC.
+func distributionMethodEnvKey(key, method string) string {
+	return key + "_" + strings.ToUpper(strings.ReplaceAll(method, "-", "_"))
+}
+
+// distributionMethodFileName suffixes the file name with the distribution method,
+// if the archive is exported with multiple methods to avoid overwriting each other's files.
+func distributionMethodFileName(name, ext, method string, multipleMethods bool) string {
+	if !multipleMethods {
+		return name + ext
+	}
+	return name + "_" + method + ext
+}
+
 func findIDEDistrubutionLogsPath(output string) (string, error) {
 	pattern := `IDEDistribution: -\[IDEDistributionLogging _createLoggingBundleAtPath:\]: Created bundle at path '(?P<log_path>.*)'`
 	re := regexp.MustCompile(pattern)
@@ -59,17 +117,100 @@ func findIDEDistrubutionLogsPath(output string) (string, error) {
 	return "", nil
 }
 
-func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, teamID string, uploadBitcode, compileBitcode bool, xcodebuildMajorVersion int64, archive xcarchive.IosArchive, manageVersionAndBuildNumber bool) (string, error) {
+// archiveProfileType returns the provisioning profile platform type of the archive's platform.
+// visionOS apps are registered as iOS apps on the Apple Developer Portal, they are signed with iOS profiles.
+func archiveProfileType(archive xcarchive.IosArchive) (profileutil.ProfileType, error) {
+	platformName, _ := archive.Application.InfoPlist.GetString("DTPlatformName")
+	if platformName == "" {
+		log.Warnf("DTPlatformName not found in the Info.plist of the application, using iOS provisioning profiles")
+		return profileutil.ProfileTypeIos, nil
+	}
+
+	switch platformName {
+	case "iphoneos", "xros":
+		return profileutil.ProfileTypeIos, nil
+	case "appletvos":
+		return profileutil.ProfileTypeTvOs, nil
+	default:
+		return "", fmt.Errorf("unsupported platform found: %s", platformName)
+	}
+}
+
+type exportOptionsGenerator struct {
+	codesignAssets  CodesignAssetProvider
+	selectionPolicy codeSignGroupSelectionPolicy
+	// profileMapping maps bundle IDs to provisioning profile names or UUIDs,
+	// these bundle IDs are signed with the mapped profiles instead of the auto-detected ones.
+	profileMapping map[string]string
+	// strict turns the warnings of the code signing heuristics into errors.
+	strict bool
+}
+
+func newExportOptionsGenerator(codesignAssets CodesignAssetProvider, selectionPolicy codeSignGroupSelectionPolicy, profileMapping map[string]string, strict bool) exportOptionsGenerator {
+	return exportOptionsGenerator{
+		codesignAssets:  codesignAssets,
+		selectionPolicy: selectionPolicy,
+		profileMapping:  profileMapping,
+		strict:          strict,
+	}
+}
+
+// codeSignGroupSelection returns the selection policy applied to the archive,
+// the preferred team is the export team, or the team the archive was signed with.
+func (g exportOptionsGenerator) codeSignGroupSelection(archiveSigningIdentity, archiveTeamID, teamID string) codeSignGroupSelection {
+	preferredTeamID := teamID
+	if preferredTeamID == "" {
+		preferredTeamID = archiveTeamID
+	}
+
+	return codeSignGroupSelection{
+		policy:                 g.selectionPolicy,
+		archiveSigningIdentity: archiveSigningIdentity,
+		preferredTeamID:        preferredTeamID,
+	}
+}
+
+// ExportCodeSigning is the code signing configuration of the export options.
+type ExportCodeSigning struct {
+	Method      exportoptions.Method
+	TeamID      string
+	Certificate certificateutil.CertificateInfoModel
+	// InstallerCertificate signs the .pkg of macOS App Store exports.
+	InstallerCertificate certificateutil.CertificateInfoModel
+	SigningStyle         string
+	// Profiles maps the bundle IDs to the provisioning profile used to sign them.
+	Profiles map[string]profileutil.ProvisioningProfileInfoModel
+}
+
+// exportOptionsConfig configures the generated export options of iOS archives, the Step builds it once from the inputs.
+type exportOptionsConfig struct {
+	Product                     ExportProduct
+	TeamID                      string
+	UploadBitcode               bool
+	CompileBitcode              bool
+	XcodebuildVersion           models.XcodebuildVersionModel
+	ManageVersionAndBuildNumber bool
+	TestFlightInternalTesting   bool
+	StripSwiftSymbols           bool
+	// Destination is the value of the destination export option, empty or export if the IPA is written to the export dir.
+	Destination string
+	// Thinning is the value of the thinning export option, empty if the IPAs are not thinned.
+	Thinning string
+}
+
+func (g exportOptionsGenerator) generateExportOptionsPlist(exportMethodStr string, archive xcarchive.IosArchive, config exportOptionsConfig) (string, ExportCodeSigning, error) {
 	log.Printf("Generating export options")
 
 	var productBundleID string
 	var exportMethod exportoptions.Method
 	exportTeamID := ""
 	exportCodeSignIdentity := ""
+	exportCertificate := certificateutil.CertificateInfoModel{}
 	exportProfileMapping := map[string]string{}
+	exportProfiles := map[string]profileutil.ProvisioningProfileInfoModel{}
 	exportCodeSignStyle := ""
 
-	switch exportProduct {
+	switch config.Product {
 	case ExportProductApp:
 		productBundleID = archive.Application.BundleIdentifier()
 	case ExportProductAppClip:
@@ -78,14 +219,14 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 
 	log.Printf("productBundleID: %s", productBundleID)
 
-	parsedMethod, err := exportoptions.ParseMethod(exportMethodStr)
+	parsedMethod, err := parseExportMethod(exportMethodStr)
 	if err != nil {
-		return "", fmt.Errorf("failed to parse export options, error: %s", err)
+		return "", ExportCodeSigning{}, fmt.Errorf("failed to parse export options, error: %s", err)
 	}
 	exportMethod = parsedMethod
 	log.Printf("export-method specified: %s", exportMethodStr)
 
-	if xcodebuildMajorVersion >= 9 {
+	if config.XcodebuildVersion.MajorVersion >= 9 {
 		log.Printf("xcode major version > 9, generating provisioningProfiles node")
 
 		fmt.Println()
@@ -105,20 +246,31 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 		fmt.Println()
 		log.Printf("Resolving CodeSignGroups...")
 
-		certs, err := certificateutil.InstalledCodesigningCertificateInfos()
+		certs, err := g.codesignAssets.ListCodesignIdentities()
 		if err != nil {
-			return "", fmt.Errorf("failed to get installed certificates, error: %s", err)
+			return "", ExportCodeSigning{}, err
 		}
-		certs = certificateutil.FilterValidCertificateInfos(certs).ValidCertificates
 
 		log.Debugf("Installed certificates:")
 		for _, certInfo := range certs {
 			log.Debugf(certInfo.String())
 		}
 
-		profs, err := profileutil.InstalledProvisioningProfileInfos(profileutil.ProfileTypeIos)
+		profileType, err := archiveProfileType(archive)
 		if err != nil {
-			return "", fmt.Errorf("failed to get installed provisioning profiles, error: %s", err)
+			return "", ExportCodeSigning{}, err
+		}
+		log.Printf("profile platform type: %s", profileType)
+
+		profs, err := g.codesignAssets.ListProvisioningProfiles(profileType)
+		if err != nil {
+			return "", ExportCodeSigning{}, err
+		}
+
+		if len(profs) == 0 {
+			if err := g.checkOtherPlatformProfiles(profileType); err != nil {
+				return "", ExportCodeSigning{}, err
+			}
 		}
 
 		log.Debugf("Installed profiles:")
@@ -126,10 +278,31 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 			log.Debugf(profileInfo.String(certs...))
 		}
 
+		mappedProfiles, err := resolveMappedProfiles(g.profileMapping, profs, archive.BundleIDEntitlementsMap(), profileType, exportMethod)
+		if err != nil {
+			return "", ExportCodeSigning{}, fmt.Errorf("invalid provisioning profile mapping: %s", err)
+		}
+
+		var unmappedBundleIDs []string
+		for _, bundleID := range bundleIDs {
+			if profile, ok := mappedProfiles[bundleID]; ok {
+				log.Printf("%s: using mapped profile %s (%s)", bundleID, profile.Name, profile.UUID)
+				continue
+			}
+			unmappedBundleIDs = append(unmappedBundleIDs, bundleID)
+		}
+
 		log.Printf("Resolving CodeSignGroups...")
-		codeSignGroups := export.CreateSelectableCodeSignGroups(certs, profs, bundleIDs)
-		if len(codeSignGroups) == 0 {
-			log.Errorf("Failed to find code signing groups for specified export method (%s)", exportMethod)
+		codeSignGroups := export.CreateSelectableCodeSignGroups(certs, profs, unmappedBundleIDs)
+
+		diagnostics := newCodeSignDiagnostics(unmappedBundleIDs)
+		diagnostics.explainMissingGroups(certs, profs, codeSignGroups)
+
+		if len(mappedProfiles) > 0 {
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterMappedProfiles, createMappedProfilesSelectableCodeSignGroupFilter(mappedProfiles), mappedProfilesRemovalReason(mappedProfiles))
+			if len(codeSignGroups) == 0 {
+				return "", ExportCodeSigning{}, fmt.Errorf("invalid provisioning profile mapping: no installed certificate is included in every mapped profile, that can sign the other targets too")
+			}
 		}
 
 		log.Debugf("\nGroups:")
@@ -145,7 +318,7 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 		if len(bundleIDEntitlementsMap) > 0 {
 			log.Warnf("Filtering CodeSignInfo groups for target capabilities")
 
-			codeSignGroups = export.FilterSelectableCodeSignGroups(codeSignGroups, export.CreateEntitlementsSelectableCodeSignGroupFilter(bundleIDEntitlementsMap))
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterEntitlements, export.CreateEntitlementsSelectableCodeSignGroupFilter(bundleIDEntitlementsMap), entitlementsRemovalReason(bundleIDEntitlementsMap))
 
 			log.Debugf("\nGroups after filtering for target capabilities:")
 			for _, group := range codeSignGroups {
@@ -155,17 +328,17 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 
 		log.Warnf("Filtering CodeSignInfo groups for export method")
 
-		codeSignGroups = export.FilterSelectableCodeSignGroups(codeSignGroups, export.CreateExportMethodSelectableCodeSignGroupFilter(exportMethod))
+		codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterExportMethod, export.CreateExportMethodSelectableCodeSignGroupFilter(exportMethod), exportMethodRemovalReason(exportMethod))
 
 		log.Debugf("\nGroups after filtering for export method:")
 		for _, group := range codeSignGroups {
 			log.Debugf(group.String())
 		}
 
-		if teamID != "" {
-			log.Warnf("Export TeamID specified: %s, filtering CodeSignInfo groups...", teamID)
+		if config.TeamID != "" {
+			log.Warnf("Export TeamID specified: %s, filtering CodeSignInfo groups...", config.TeamID)
 
-			codeSignGroups = export.FilterSelectableCodeSignGroups(codeSignGroups, export.CreateTeamSelectableCodeSignGroupFilter(teamID))
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterTeam, export.CreateTeamSelectableCodeSignGroupFilter(config.TeamID), teamRemovalReason(config.TeamID))
 
 			log.Debugf("\nGroups after filtering for team ID:")
 			for _, group := range codeSignGroups {
@@ -178,7 +351,7 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 				"only NOT xcode managed profiles are allowed to sign when exporting the archive.\n" +
 				"Removing xcode managed CodeSignInfo groups")
 
-			codeSignGroups = export.FilterSelectableCodeSignGroups(codeSignGroups, export.CreateNotXcodeManagedSelectableCodeSignGroupFilter())
+			codeSignGroups = diagnostics.filter(codeSignGroups, codeSignFilterNotXcodeManaged, export.CreateNotXcodeManagedSelectableCodeSignGroupFilter(), notXcodeManagedRemovalReason)
 
 			log.Debugf("\nGroups after filtering for NOT Xcode managed profiles:")
 			for _, group := range codeSignGroups {
@@ -187,13 +360,14 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 		}
 
 		defaultProfileURL := os.Getenv("BITRISE_DEFAULT_PROVISION_URL")
-		if teamID == "" && defaultProfileURL != "" {
+		if config.TeamID == "" && defaultProfileURL != "" {
 			if defaultProfile, err := getDefaultProvisioningProfile(); err == nil {
 				log.Debugf("\ndefault profile: %v\n", defaultProfile)
-				filteredCodeSignGroups := export.FilterSelectableCodeSignGroups(codeSignGroups,
-					export.CreateExcludeProfileNameSelectableCodeSignGroupFilter(defaultProfile.Name))
+				filteredCodeSignGroups, removals := filterCodeSignGroups(codeSignGroups, codeSignFilterDefaultProfile,
+					export.CreateExcludeProfileNameSelectableCodeSignGroupFilter(defaultProfile.Name), excludeProfileNameRemovalReason(defaultProfile.Name))
 				if len(filteredCodeSignGroups) > 0 {
 					codeSignGroups = filteredCodeSignGroups
+					diagnostics.removals = append(diagnostics.removals, removals...)
 
 					log.Debugf("\nGroups after removing default profile:")
 					for _, group := range codeSignGroups {
@@ -210,6 +384,8 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 			for bundleID, profiles := range selectable.BundleIDProfilesMap {
 				if len(profiles) > 0 {
 					bundleIDProfileMap[bundleID] = profiles[0]
+				} else if g.strict {
+					return "", ExportCodeSigning{}, missingProfileError{BundleID: bundleID}
 				} else {
 					log.Warnf("No profile available to sign (%s) target!", bundleID)
 				}
@@ -227,45 +403,62 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 		}
 
 		if len(iosCodeSignGroups) > 0 {
-			codeSignGroup := export.IosCodeSignGroup{}
-
-			if len(iosCodeSignGroups) >= 1 {
-				codeSignGroup = iosCodeSignGroups[0]
+			var groups []export.CodeSignGroup
+			for i := range iosCodeSignGroups {
+				groups = append(groups, &iosCodeSignGroups[i])
 			}
-			if len(iosCodeSignGroups) > 1 {
-				log.Warnf("Multiple code signing groups found! Using the first code signing group")
+
+			if g.strict && len(groups) > 1 {
+				return "", ExportCodeSigning{}, newMultipleCodeSignGroupsError(groups)
 			}
 
+			selection := g.codeSignGroupSelection(archive.SigningIdentity(), archive.Application.ProvisioningProfile.TeamID, config.TeamID)
+			codeSignGroup := selection.selectGroup(groups)
+
 			exportTeamID = codeSignGroup.Certificate().TeamID
 			exportCodeSignIdentity = codeSignGroup.Certificate().CommonName
+			exportCertificate = codeSignGroup.Certificate()
 
+			bundleIDProfileMap := map[string]profileutil.ProvisioningProfileInfoModel{}
 			for bundleID, profileInfo := range codeSignGroup.BundleIDProfileMap() {
+				bundleIDProfileMap[bundleID] = profileInfo
+			}
+			for bundleID, profileInfo := range mappedProfiles {
+				bundleIDProfileMap[bundleID] = profileInfo
+			}
+
+			for _, bundleID := range sortedBundleIDs(bundleIDProfileMap) {
+				profileInfo := bundleIDProfileMap[bundleID]
 				exportProfileMapping[bundleID] = profileInfo.Name
+				exportProfiles[bundleID] = profileInfo
 
-				isXcodeManaged := profileutil.IsXcodeManaged(profileInfo.Name)
-				if isXcodeManaged {
-					if exportCodeSignStyle != "" && exportCodeSignStyle != "automatic" {
-						log.Errorf("Both xcode managed and NON xcode managed profiles in code signing group")
-					}
-					exportCodeSignStyle = "automatic"
-				} else {
-					if exportCodeSignStyle != "" && exportCodeSignStyle != "manual" {
-						log.Errorf("Both xcode managed and NON xcode managed profiles in code signing group")
+				codeSignStyle := "manual"
+				if profileutil.IsXcodeManaged(profileInfo.Name) {
+					codeSignStyle = "automatic"
+				}
+				if exportCodeSignStyle != "" && exportCodeSignStyle != codeSignStyle {
+					if g.strict {
+						return "", ExportCodeSigning{}, mixedSigningStyleError{BundleID: bundleID}
 					}
-					exportCodeSignStyle = "manual"
+					log.Errorf("Both xcode managed and NON xcode managed profiles in code signing group")
 				}
+				exportCodeSignStyle = codeSignStyle
 			}
 		} else {
-			log.Errorf("Failed to find Codesign Groups")
+			return "", ExportCodeSigning{}, diagnostics.noGroupError(exportMethod)
+		}
+
+		if g.strict && archive.Application.ProvisioningProfile.IsXcodeManaged() && exportCodeSignStyle == "manual" {
+			return "", ExportCodeSigning{}, signingStyleSwitchError{}
 		}
 	}
 
 	var exportOpts exportoptions.ExportOptions
 	if exportMethod == exportoptions.MethodAppStore {
 		options := exportoptions.NewAppStoreOptions()
-		options.UploadBitcode = uploadBitcode
+		options.UploadBitcode = config.UploadBitcode
 
-		if xcodebuildMajorVersion >= 9 {
+		if config.XcodebuildVersion.MajorVersion >= 9 {
 			options.BundleIDProvisioningProfileMapping = exportProfileMapping
 			options.SigningCertificate = exportCodeSignIdentity
 			options.TeamID = exportTeamID
@@ -279,22 +472,25 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 			}
 		}
 
-		if xcodebuildMajorVersion >= 13 {
+		if config.XcodebuildVersion.MajorVersion >= 13 {
 			log.Debugf("Setting flag for managing app version and build number")
 
-			options.ManageAppVersion = manageVersionAndBuildNumber
+			options.ManageAppVersion = config.ManageVersionAndBuildNumber
 		}
 
 		exportOpts = options
 	} else {
 		options := exportoptions.NewNonAppStoreOptions(exportMethod)
-		options.CompileBitcode = compileBitcode
+		options.CompileBitcode = config.CompileBitcode
+		if config.Thinning != "" {
+			options.Thinning = config.Thinning
+		}
 
-		if xcodebuildMajorVersion >= 12 {
+		if config.XcodebuildVersion.MajorVersion >= 12 {
 			options.DistributionBundleIdentifier = productBundleID
 		}
 
-		if xcodebuildMajorVersion >= 9 {
+		if config.XcodebuildVersion.MajorVersion >= 9 {
 			options.BundleIDProvisioningProfileMapping = exportProfileMapping
 			options.SigningCertificate = exportCodeSignIdentity
 			options.TeamID = exportTeamID
@@ -311,7 +507,71 @@ func generateExportOptionsPlist(exportProduct ExportProduct, exportMethodStr, te
 		exportOpts = options
 	}
 
-	return exportOpts.String()
+	exportOptionsHash := exportOpts.Hash()
+
+	if !config.StripSwiftSymbols && !isXcodeVersionAtLeast(config.XcodebuildVersion, 15, 3) {
+		log.Warnf("Keeping the Swift symbols requires Xcode 15.3 or later, ignoring it")
+	} else if !config.StripSwiftSymbols {
+		exportOptionsHash[stripSwiftSymbolsKey] = false
+	}
+
+	if config.Destination == destinationUpload && exportMethod != exportoptions.MethodAppStore {
+		log.Warnf("Uploading to App Store Connect is supported for app-store exports, ignoring it")
+	} else if config.Destination == destinationUpload && !isXcodeVersionAtLeast(config.XcodebuildVersion, 15, 3) {
+		log.Warnf("Uploading to App Store Connect requires Xcode 15.3 or later, ignoring it")
+	} else if config.Destination == destinationUpload {
+		exportOptionsHash[destinationKey] = destinationUpload
+	}
+
+	if config.Thinning != "" && exportMethod == exportoptions.MethodAppStore {
+		log.Warnf("App Thinning is supported for non-App Store exports, ignoring it")
+	}
+
+	if config.TestFlightInternalTesting && exportMethod != exportoptions.MethodAppStore {
+		log.Warnf("TestFlight internal testing only is supported for app-store exports, ignoring it")
+	} else if config.TestFlightInternalTesting && !isXcodeVersionAtLeast(config.XcodebuildVersion, 15, 3) {
+		log.Warnf("TestFlight internal testing only requires Xcode 15.3 or later, ignoring it")
+	} else if config.TestFlightInternalTesting {
+		exportOptionsHash[testFlightInternalTestingOnlyKey] = true
+	}
+
+	exportOptionsContent, err := exportOptionsString(exportOptionsHash)
+	if err != nil {
+		return "", ExportCodeSigning{}, err
+	}
+
+	return exportOptionsContent, ExportCodeSigning{
+		Method:       exportMethod,
+		TeamID:       exportTeamID,
+		Certificate:  exportCertificate,
+		SigningStyle: exportCodeSignStyle,
+		Profiles:     exportProfiles,
+	}, nil
+}
+
+// checkOtherPlatformProfiles returns an error if no profile is available for the archive's platform,
+// but there are profiles of other platforms, instead of generating export options without profiles.
+func (g exportOptionsGenerator) checkOtherPlatformProfiles(profileType profileutil.ProfileType) error {
+	var otherProfileTypes []string
+	for _, otherProfileType := range []profileutil.ProfileType{profileutil.ProfileTypeIos, profileutil.ProfileTypeTvOs} {
+		if otherProfileType == profileType {
+			continue
+		}
+
+		profs, err := g.codesignAssets.ListProvisioningProfiles(otherProfileType)
+		if err != nil {
+			return err
+		}
+		if len(profs) > 0 {
+			otherProfileTypes = append(otherProfileTypes, string(otherProfileType))
+		}
+	}
+
+	if len(otherProfileTypes) > 0 {
+		return fmt.Errorf("no %s provisioning profile found for the archive, only profiles for other platforms (%s) are available", profileType, strings.Join(otherProfileTypes, ", "))
+	}
+
+	return nil
 }
 
 func getDefaultProvisioningProfile() (profileutil.ProvisioningProfileInfoModel, error) {
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/bitrise-io/go-utils/log"
+	"github.com/ryanuber/go-glob"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/plistutil"
+	"github.com/teamlapse/go-xcode/profileutil"
+	"github.com/teamlapse/go-xcode/v2/xcarchive"
+)
+
+// ipaTarget is an application or app extension bundle embedded in an exported IPA.
+type ipaTarget struct {
+	// Path is the path of the bundle within the IPA, for example: Payload/Sample.app/PlugIns/Widget.appex
+	Path     string
+	BundleID string
+	// Profile is nil if the bundle has no embedded provisioning profile.
+	Profile  *profileutil.ProvisioningProfileInfoModel
+	Problems []string
+}
+
+// readIPATargets parses the Info.plist and the embedded provisioning profile of every .app and .appex of the IPA.
+func readIPATargets(ipaPath string) ([]ipaTarget, error) {
+	reader, err := zip.OpenReader(ipaPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ipa (%s), error: %s", ipaPath, err)
+	}
+	defer func() {
+		if err := reader.Close(); err != nil {
+			log.Warnf("Failed to close ipa (%s), error: %s", ipaPath, err)
+		}
+	}()
+
+	files := map[string]*zip.File{}
+	var bundlePaths []string
+	for _, file := range reader.File {
+		files[file.Name] = file
+
+		dir, name := path.Split(file.Name)
+		dir = strings.TrimSuffix(dir, "/")
+		if name == "Info.plist" && (strings.HasSuffix(dir, ".app") || strings.HasSuffix(dir, ".appex")) {
+			bundlePaths = append(bundlePaths, dir)
+		}
+	}
+	sort.Strings(bundlePaths)
+
+	var targets []ipaTarget
+	for _, bundlePath := range bundlePaths {
+		infoPlistContent, err := readZipFile(files[bundlePath+"/Info.plist"])
+		if err != nil {
+			return nil, err
+		}
+		infoPlist, err := plistutil.NewPlistDataFromContent(string(infoPlistContent))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s/Info.plist, error: %s", bundlePath, err)
+		}
+
+		target := ipaTarget{
+			Path:     bundlePath,
+			BundleID: stringValue(infoPlist, "CFBundleIdentifier"),
+		}
+
+		if profileFile, ok := files[bundlePath+"/embedded.mobileprovision"]; ok {
+			profileContent, err := readZipFile(profileFile)
+			if err != nil {
+				return nil, err
+			}
+			pkcs7Profile, err := profileutil.ProvisioningProfileFromContent(profileContent)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s/embedded.mobileprovision, error: %s", bundlePath, err)
+			}
+			profile, err := profileutil.NewProvisioningProfileInfo(*pkcs7Profile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse %s/embedded.mobileprovision, error: %s", bundlePath, err)
+			}
+			target.Profile = &profile
+		}
+
+		targets = append(targets, target)
+	}
+
+	return targets, nil
+}
+
+func readZipFile(file *zip.File) ([]byte, error) {
+	rc, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("failed to open %s, error: %s", file.Name, err)
+	}
+	defer func() {
+		if err := rc.Close(); err != nil {
+			log.Warnf("Failed to close %s, error: %s", file.Name, err)
+		}
+	}()
+
+	content, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s, error: %s", file.Name, err)
+	}
+	return content, nil
+}
+
+// verifyIPATargets checks the embedded provisioning profiles against the requested code signing settings,
+// and returns true if no problem was found.
+// archiveProfiles maps the bundle IDs of the archive to the provisioning profiles used for archiving.
+func verifyIPATargets(targets []ipaTarget, codeSigning ExportCodeSigning, archiveProfiles map[string]profileutil.ProvisioningProfileInfoModel) bool {
+	valid := true
+	for i := range targets {
+		target := &targets[i]
+		profile := target.Profile
+		if profile == nil {
+			target.Problems = append(target.Problems, "no embedded provisioning profile")
+			valid = false
+			continue
+		}
+
+		if codeSigning.TeamID != "" && profile.TeamID != codeSigning.TeamID {
+			target.Problems = append(target.Problems, fmt.Sprintf("team (%s) differs from the requested one (%s)", profile.TeamID, codeSigning.TeamID))
+		}
+
+		if codeSigning.Method != "" && profile.ExportType != codeSigning.Method {
+			target.Problems = append(target.Problems, fmt.Sprintf("%s profile, requested export method: %s", profile.ExportType, codeSigning.Method))
+		}
+
+		if !glob.Glob(profile.BundleID, target.BundleID) {
+			target.Problems = append(target.Problems, fmt.Sprintf("profile is for a different bundle ID: %s", profile.BundleID))
+		}
+
+		if expected, ok := codeSigning.Profiles[target.BundleID]; ok && codeSigning.SigningStyle == "manual" {
+			if (expected.UUID != "" && expected.UUID != profile.UUID) || (expected.UUID == "" && expected.Name != "" && expected.Name != profile.Name && expected.Name != profile.UUID) {
+				target.Problems = append(target.Problems, fmt.Sprintf("profile differs from the selected one (%s)", expected.Name))
+			}
+		}
+
+		if archiveProfile, ok := archiveProfiles[target.BundleID]; ok && codeSigning.Method != exportoptions.MethodDevelopment &&
+			archiveProfile.ExportType == exportoptions.MethodDevelopment && archiveProfile.UUID == profile.UUID {
+			target.Problems = append(target.Problems, "still signed with the development profile used for archiving")
+		}
+
+		if len(target.Problems) > 0 {
+			valid = false
+		}
+	}
+
+	return valid
+}
+
+// ipaVerificationTable prints the verification result of every target.
+func ipaVerificationTable(targets []ipaTarget) string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TARGET\tBUNDLE ID\tPROFILE\tTEAM\tTYPE\tRESULT")
+	for _, target := range targets {
+		profileName, teamID, exportType := "-", "-", "-"
+		if target.Profile != nil {
+			profileName = fmt.Sprintf("%s (%s)", target.Profile.Name, target.Profile.UUID)
+			teamID = target.Profile.TeamID
+			exportType = string(target.Profile.ExportType)
+		}
+
+		result := "OK"
+		if len(target.Problems) > 0 {
+			result = strings.Join(target.Problems, "; ")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", path.Base(target.Path), target.BundleID, profileName, teamID, exportType, result)
+	}
+	if err := w.Flush(); err != nil {
+		log.Warnf("Failed to print verification table, error: %s", err)
+	}
+	return buf.String()
+}
+
+// verifyExportedIPAs verifies the code signing of every IPA exported with the distribution method.
+func (s Step) verifyExportedIPAs(export MethodExport, archive xcarchive.IosArchive) error {
+	ipas, err := exportedIPAPaths(export)
+	if err != nil {
+		return err
+	}
+
+	archiveProfiles := map[string]profileutil.ProvisioningProfileInfoModel{}
+	for _, app := range archiveApplications(archive) {
+		archiveProfiles[app.BundleIdentifier()] = app.ProvisioningProfile
+	}
+
+	s.logger.Infof("Verifying the code signing of the %s export", export.DistributionMethod)
+
+	var invalidIPAs []string
+	for _, ipa := range ipas {
+		targets, err := readIPATargets(ipa)
+		if err != nil {
+			return fmt.Errorf("failed to verify %s, error: %s", filepath.Base(ipa), err)
+		}
+
+		valid := verifyIPATargets(targets, export.CodeSigning, archiveProfiles)
+
+		s.logger.Printf("%s:", filepath.Base(ipa))
+		s.logger.Printf("%s", ipaVerificationTable(targets))
+
+		if !valid {
+			invalidIPAs = append(invalidIPAs, filepath.Base(ipa))
+		}
+	}
+
+	if len(invalidIPAs) > 0 {
+		return fmt.Errorf("code signing verification of the %s export failed: %s", export.DistributionMethod, strings.Join(invalidIPAs, ", "))
+	}
+
+	s.logger.Donef("Code signing verified")
+	s.logger.Println()
+
+	return nil
+}
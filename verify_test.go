@@ -0,0 +1,119 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/teamlapse/go-xcode/exportoptions"
+	"github.com/teamlapse/go-xcode/profileutil"
+)
+
+func TestReadIPATargets(t *testing.T) {
+	// Given
+	ipaPath := filepath.Join(t.TempDir(), "Sample.ipa")
+	file, err := os.Create(ipaPath)
+	if err != nil {
+		t.Fatalf("failed to create ipa: %s", err)
+	}
+	writer := zip.NewWriter(file)
+	for name, bundleID := range map[string]string{
+		"Payload/Sample.app/Info.plist":                                testBundleID,
+		"Payload/Sample.app/PlugIns/Widget.appex/Info.plist":           testBundleID + ".widget",
+		"Payload/Sample.app/Frameworks/Sample.framework/Info.plist":    "io.bitrise.framework",
+		"Payload/Sample.app/PlugIns/Widget.appex/Resources/Info.plist": "io.bitrise.resource",
+	} {
+		w, err := writer.Create(name)
+		if err != nil {
+			t.Fatalf("failed to create zip entry: %s", err)
+		}
+		_, err = w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0"><dict><key>CFBundleIdentifier</key><string>` + bundleID + `</string></dict></plist>`))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+	assert.NoError(t, file.Close())
+
+	// When
+	targets, err := readIPATargets(ipaPath)
+
+	// Then
+	assert.NoError(t, err)
+	assert.Equal(t, []ipaTarget{
+		{Path: "Payload/Sample.app", BundleID: testBundleID},
+		{Path: "Payload/Sample.app/PlugIns/Widget.appex", BundleID: testBundleID + ".widget"},
+	}, targets)
+}
+
+func TestVerifyIPATargets(t *testing.T) {
+	// Given
+	certificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	appStoreProfile := testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, certificate)
+	developmentProfile := testProfile("Sample Development", "development-uuid", exportoptions.MethodDevelopment, certificate)
+	otherTeamProfile := testProfile("Widget App Store", "widget-uuid", exportoptions.MethodAppStore, certificate)
+	otherTeamProfile.BundleID = testBundleID + ".widget"
+	otherTeamProfile.TeamID = "OTHERTEAM1"
+
+	codeSigning := ExportCodeSigning{
+		Method:       exportoptions.MethodAppStore,
+		TeamID:       testTeamID,
+		SigningStyle: "manual",
+		Profiles: map[string]profileutil.ProvisioningProfileInfoModel{
+			testBundleID: appStoreProfile,
+		},
+	}
+	archiveProfiles := map[string]profileutil.ProvisioningProfileInfoModel{
+		testBundleID: developmentProfile,
+	}
+
+	targets := []ipaTarget{
+		{Path: "Payload/Sample.app", BundleID: testBundleID, Profile: &appStoreProfile},
+		{Path: "Payload/Sample.app/PlugIns/Widget.appex", BundleID: testBundleID + ".widget", Profile: &otherTeamProfile},
+		{Path: "Payload/Sample.app/PlugIns/Intents.appex", BundleID: testBundleID + ".intents"},
+	}
+
+	// When
+	valid := verifyIPATargets(targets, codeSigning, archiveProfiles)
+
+	// Then
+	assert.False(t, valid)
+	assert.Empty(t, targets[0].Problems)
+	assert.Equal(t, []string{"team (OTHERTEAM1) differs from the requested one (72SA8V3WYL)"}, targets[1].Problems)
+	assert.Equal(t, []string{"no embedded provisioning profile"}, targets[2].Problems)
+
+	// When
+	targets[0].Profile = &developmentProfile
+	verifyIPATargets(targets[:1], codeSigning, archiveProfiles)
+
+	// Then
+	assert.Equal(t, []string{
+		"development profile, requested export method: app-store",
+		"profile differs from the selected one (Sample App Store)",
+		"still signed with the development profile used for archiving",
+	}, targets[0].Problems)
+	assert.Contains(t, ipaVerificationTable(targets[:1]), "Sample.app  io.bitrise.sample  Sample Development (development-uuid)")
+}
+
+func TestVerifyIPATargets_defaultSigningStyle(t *testing.T) {
+	// Given
+	certificate := testCertificate("1", "Apple Distribution: Bitrise Bot (72SA8V3WYL)")
+	selectedProfile := testProfile("Sample App Store", "app-store-uuid", exportoptions.MethodAppStore, certificate)
+	managedProfile := testProfile("iOS Team Store Provisioning Profile: io.bitrise.sample", "managed-uuid", exportoptions.MethodAppStore, certificate)
+
+	// The export options content omits signingStyle, xcodebuild signs automatically then
+	codeSigning := ExportCodeSigning{
+		Method:   exportoptions.MethodAppStore,
+		TeamID:   testTeamID,
+		Profiles: map[string]profileutil.ProvisioningProfileInfoModel{testBundleID: selectedProfile},
+	}
+	targets := []ipaTarget{{Path: "Payload/Sample.app", BundleID: testBundleID, Profile: &managedProfile}}
+
+	// When
+	valid := verifyIPATargets(targets, codeSigning, nil)
+
+	// Then
+	assert.True(t, valid)
+	assert.Empty(t, targets[0].Problems)
+}